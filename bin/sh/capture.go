@@ -0,0 +1,79 @@
+//
+// capture.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// captureBuffer collects the captured output. The background jobs
+// write to it concurrently with the script. It does not implement
+// io.ReaderFrom because bytes.Buffer.ReadFrom discards the writes
+// made while it is blocked reading its source.
+type captureBuffer struct {
+	m   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *captureBuffer) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.String()
+}
+
+// EvalCapture evaluates the script and returns its standard output
+// and standard error, and the exit status of the last command. The
+// commands read their standard input from p.Stdin. The returned error
+// is set for syntax errors which terminate the script evaluation.
+func EvalCapture(p *Process, script string) (
+	stdout, stderr string, status int, err error) {
+
+	var outBuf, errBuf captureBuffer
+
+	cp := &Process{
+		Stdin:      p.Stdin,
+		Stdout:     &outBuf,
+		Stderr:     &errBuf,
+		WorkingDir: p.WorkingDir,
+		Args:       p.Args,
+		Env:        p.Env,
+		Status:     p.Status,
+		Interrupt:  p.Interrupt,
+	}
+	if cp.Env == nil {
+		cp.Env = newEnv()
+	}
+
+	// The exit builtin and the signals terminate the script, not
+	// the shell. The script has its own traps and it runs outside
+	// of the loops.
+	saved, savedTraps, savedKilled := running, traps, killed
+	running, traps, killed = true, make(map[Signal]string), false
+	savedLoop := loop
+	loop = loopState{}
+	defer func() {
+		running, traps, killed = saved, savedTraps, savedKilled
+		loop = savedLoop
+	}()
+
+	lines := strings.Split(strings.TrimSuffix(script, "\n"), "\n")
+	status, err = evalLines(cp, lines)
+	runInterruptTrap(cp)
+	runExitTrap(cp)
+
+	return outBuf.String(), errBuf.String(), status, err
+}
@@ -0,0 +1,98 @@
+//
+// capture_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var captureTests = []struct {
+	script string
+	stdout string
+	stderr string
+	status int
+	err    bool
+}{
+	{
+		script: `cat <<EOF
+Hello, world!
+EOF
+`,
+		stdout: "Hello, world!\n",
+	},
+	{
+		script: `cat <<EOF
+first
+EOF
+nosuchcommand
+`,
+		stdout: "first\n",
+		stderr: "nosuchcommand: " + errCapture.Error() + "\n",
+		status: 1,
+	},
+	{
+		script: `cat <<EOF
+before exit
+EOF
+exit
+cat <<EOF
+after exit
+EOF
+`,
+		stdout: "before exit\n",
+	},
+	{
+		script: `cut -d " " -f 2,3 <<EOF
+a b c d
+EOF
+`,
+		stdout: "b c\n",
+	},
+	{
+		script: `cat "unterminated`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `cat <<EOF
+unterminated
+`,
+		status: 1,
+		err:    true,
+	},
+}
+
+func TestEvalCapture(t *testing.T) {
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range captureTests {
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil != test.err {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout: got %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr: got %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status: got %d, expected %d",
+				test.script, status, test.status)
+		}
+	}
+	if !running {
+		t.Errorf("EvalCapture: exit terminated the shell")
+	}
+}
@@ -0,0 +1,190 @@
+//
+// cmd_cond.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "true",
+			Usage: "true",
+			Help:  "Exit with status 0.",
+			Cmd: func(p *Process, args []string) int {
+				return 0
+			},
+		},
+		Builtin{
+			Name:  "false",
+			Usage: "false",
+			Help:  "Exit with status 1.",
+			Cmd: func(p *Process, args []string) int {
+				return 1
+			},
+		},
+		Builtin{
+			Name:  "test",
+			Usage: "test expr",
+			Help:  "Evaluate the conditional expression expr.",
+			Cmd:   cmd_test,
+		},
+		Builtin{
+			Name:  "[",
+			Usage: "[ expr ]",
+			Help:  "Evaluate the conditional expression expr.",
+			Cmd:   cmd_test,
+		},
+	}...)
+}
+
+// cmd_test evaluates the conditional expression. It returns 0 if the
+// expression is true, 1 if it is false, and 2 on errors.
+func cmd_test(p *Process, args []string) int {
+	name := args[0]
+	args = args[1:]
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintf(p.Stderr, "[: missing `]'\n")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+
+	result, err := testExpr(p, args)
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "%s: %s\n", name, err)
+		return 2
+	}
+	if result {
+		return 0
+	}
+	return 1
+}
+
+// testExpr evaluates the expression by the number of its arguments
+// as specified by POSIX. The binary operators take precedence over
+// the ! negation so that "! = !" compares two strings.
+func testExpr(p *Process, args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+
+	case 1:
+		return len(args[0]) > 0, nil
+
+	case 2:
+		if args[0] == "!" {
+			return testNegate(testExpr(p, args[1:]))
+		}
+		return testUnary(p, args[0], args[1])
+
+	case 3:
+		if isBinary(args[1]) {
+			return testBinary(args[0], args[1], args[2])
+		}
+		if args[0] == "!" {
+			return testNegate(testExpr(p, args[1:]))
+		}
+		return testBinary(args[0], args[1], args[2])
+
+	case 4:
+		if args[0] == "!" {
+			return testNegate(testExpr(p, args[1:]))
+		}
+		return false, fmt.Errorf("too many arguments")
+
+	default:
+		return false, fmt.Errorf("too many arguments")
+	}
+}
+
+// testNegate negates the result of a successful test.
+func testNegate(result bool, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	return !result, nil
+}
+
+// isBinary tests if the argument is a binary operator.
+func isBinary(op string) bool {
+	switch op {
+	case "=", "==", "!=", "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		return true
+	}
+	return false
+}
+
+func testUnary(p *Process, op, arg string) (bool, error) {
+	switch op {
+	case "-z":
+		return len(arg) == 0, nil
+
+	case "-n":
+		return len(arg) > 0, nil
+
+	case "-e", "-f", "-d":
+		info, err := os.Stat(p.Path(arg))
+		if err != nil {
+			return false, nil
+		}
+		switch op {
+		case "-f":
+			return info.Mode().IsRegular(), nil
+		case "-d":
+			return info.IsDir(), nil
+		default:
+			return true, nil
+		}
+
+	default:
+		return false, fmt.Errorf("%s: unary operator expected", op)
+	}
+}
+
+func testBinary(a, op, b string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return a == b, nil
+
+	case "!=":
+		return a != b, nil
+
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		x, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", a)
+		}
+		y, err := strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", b)
+		}
+		switch op {
+		case "-eq":
+			return x == y, nil
+		case "-ne":
+			return x != y, nil
+		case "-lt":
+			return x < y, nil
+		case "-le":
+			return x <= y, nil
+		case "-gt":
+			return x > y, nil
+		default:
+			return x >= y, nil
+		}
+
+	default:
+		return false, fmt.Errorf("%s: binary operator expected", op)
+	}
+}
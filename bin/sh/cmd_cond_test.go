@@ -0,0 +1,131 @@
+//
+// cmd_cond_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+var condTests = []struct {
+	args   []string
+	status int
+}{
+	{[]string{"true"}, 0},
+	{[]string{"false"}, 1},
+
+	// Strings.
+	{[]string{"test", "abc", "=", "abc"}, 0},
+	{[]string{"test", "abc", "=", "abd"}, 1},
+	{[]string{"test", "abc", "!=", "abd"}, 0},
+	{[]string{"test", "-z", ""}, 0},
+	{[]string{"test", "-z", "abc"}, 1},
+	{[]string{"test", "-n", "abc"}, 0},
+	{[]string{"test", "-n", ""}, 1},
+	{[]string{"test", "abc"}, 0},
+	{[]string{"test", ""}, 1},
+	{[]string{"test"}, 1},
+	{[]string{"test", "!", "-n", ""}, 0},
+	{[]string{"test", "!", "=", "!"}, 0},
+	{[]string{"test", "!", "!=", "!"}, 1},
+	{[]string{"test", "!", "abc"}, 1},
+	{[]string{"test", "!", ""}, 0},
+	{[]string{"test", "!"}, 0},
+	{[]string{"test", "!", "abc", "=", "abd"}, 0},
+	{[]string{"test", "!", "!", "abc"}, 0},
+	{[]string{"[", "!", "=", "!", "]"}, 0},
+
+	// Integers.
+	{[]string{"test", "1", "-eq", "1"}, 0},
+	{[]string{"test", "1", "-ne", "1"}, 1},
+	{[]string{"test", "-2", "-lt", "1"}, 0},
+	{[]string{"test", "2", "-le", "1"}, 1},
+	{[]string{"test", "10", "-gt", "9"}, 0},
+	{[]string{"test", "9", "-ge", "10"}, 1},
+	{[]string{"test", "a", "-eq", "1"}, 2},
+
+	// Brackets.
+	{[]string{"[", "abc", "=", "abc", "]"}, 0},
+	{[]string{"[", "abc", "=", "abd", "]"}, 1},
+	{[]string{"[", "]"}, 1},
+	{[]string{"[", "abc", "=", "abc"}, 2},
+	{[]string{"[", "1", "-lt", "2", "]", "]"}, 2},
+
+	// Errors.
+	{[]string{"test", "a", "-foo", "b"}, 2},
+	{[]string{"test", "-foo", "b"}, 2},
+	{[]string{"test", "a", "b", "c", "d"}, 2},
+}
+
+func TestCond(t *testing.T) {
+	for _, test := range condTests {
+		p := &Process{
+			Stdin:  strings.NewReader(""),
+			Stdout: new(bytes.Buffer),
+			Stderr: new(bytes.Buffer),
+		}
+		status, err := runCommand(p, test.args)
+		if err != nil {
+			t.Errorf("%q: %s", test.args, err)
+			continue
+		}
+		if status != test.status {
+			t.Errorf("%q: got status %d, expected %d",
+				test.args, status, test.status)
+		}
+	}
+}
+
+func TestCondFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "file")
+	err = ioutil.WriteFile(file, []byte("data"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := path.Join(dir, "missing")
+
+	tests := []struct {
+		script string
+		status int
+	}{
+		{"test -e " + file, 0},
+		{"test -f " + file, 0},
+		{"test -d " + file, 1},
+		{"test -e " + dir, 0},
+		{"test -f " + dir, 1},
+		{"test -d " + dir, 0},
+		{"test -e " + missing, 1},
+		{"[ -f " + missing + " ]", 1},
+		{"[ ! -f " + missing + " ]", 0},
+	}
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range tests {
+		_, _, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%s: %s", test.script, err)
+			continue
+		}
+		if status != test.status {
+			t.Errorf("%s: got status %d, expected %d",
+				test.script, status, test.status)
+		}
+	}
+}
@@ -10,17 +10,139 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
 )
 
 func init() {
-	builtin = append(builtin, Builtin{
-		Name: "date",
-		Cmd:  cmd_date,
-	})
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "date",
+			Usage: "date",
+			Help:  "Print the current date and time.",
+			Cmd:   cmd_date,
+		},
+		Builtin{
+			Name:  "sleep",
+			Usage: "sleep seconds",
+			Help:  "Pause for the number of seconds.",
+			Cmd:   cmd_sleep,
+		},
+		Builtin{
+			Name:  "uptime",
+			Usage: "uptime",
+			Help:  "Print how long the system has been running.",
+			Cmd:   cmd_uptime,
+		},
+		Builtin{
+			Name:  "cal",
+			Usage: "cal [month [year]]",
+			Help:  "Print the calendar of the month.",
+			Cmd:   cmd_cal,
+		},
+	}...)
+}
+
+// uptime returns how long the kernel has been running.
+var uptime = bbos.Uptime
+
+func cmd_date(p *Process, args []string) int {
+	now := time.Now()
+	fmt.Fprintf(p.Stdout, "%s\n", now.Format(time.UnixDate))
+	return 0
+}
+
+func cmd_sleep(p *Process, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(p.Stderr, "usage: sleep seconds\n")
+		return 2
+	}
+	secs, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || secs < 0 {
+		fmt.Fprintf(p.Stderr, "sleep: invalid time interval: %s\n", args[1])
+		return 1
+	}
+	select {
+	case <-time.After(time.Duration(secs * float64(time.Second))):
+		return 0
+	case <-p.Interrupt.Done():
+		return p.Interrupt.Signalled().ExitStatus()
+	}
+}
+
+func cmd_uptime(p *Process, args []string) int {
+	d, err := uptime()
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "uptime: %s\n", err)
+		return 1
+	}
+	fmt.Fprintf(p.Stdout, "up %s\n", formatUptime(d))
+	return 0
+}
+
+// formatUptime formats the duration as days and hh:mm:ss.
+func formatUptime(d time.Duration) string {
+	secs := int64(d / time.Second)
+	days := secs / (24 * 60 * 60)
+	secs %= 24 * 60 * 60
+
+	result := fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	switch days {
+	case 0:
+		return result
+	case 1:
+		return "1 day, " + result
+	default:
+		return fmt.Sprintf("%d days, %s", days, result)
+	}
 }
 
-func cmd_date(args []string) {
+// cmd_cal prints the calendar of the month. The month and year
+// default to the current month.
+func cmd_cal(p *Process, args []string) int {
 	now := time.Now()
-	fmt.Printf("%s\n", now.Format(time.UnixDate))
+	month := now.Month()
+	year := now.Year()
+
+	if len(args) > 3 {
+		fmt.Fprintf(p.Stderr, "usage: cal [month [year]]\n")
+		return 2
+	}
+	if len(args) > 1 {
+		m, err := strconv.Atoi(args[1])
+		if err != nil || m < 1 || m > 12 {
+			fmt.Fprintf(p.Stderr, "cal: invalid month: %s\n", args[1])
+			return 1
+		}
+		month = time.Month(m)
+	}
+	if len(args) > 2 {
+		y, err := strconv.Atoi(args[2])
+		if err != nil || y < 1 || y > 9999 {
+			fmt.Fprintf(p.Stderr, "cal: invalid year: %s\n", args[2])
+			return 1
+		}
+		year = y
+	}
+
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	days := first.AddDate(0, 1, -1).Day()
+
+	title := fmt.Sprintf("%s %d", month, year)
+	fmt.Fprintf(p.Stdout, "%s%s\n", strings.Repeat(" ", (20-len(title))/2),
+		title)
+	fmt.Fprintf(p.Stdout, "Su Mo Tu We Th Fr Sa\n")
+
+	line := strings.Repeat("   ", int(first.Weekday()))
+	for day := 1; day <= days; day++ {
+		line += fmt.Sprintf("%2d ", day)
+		if len(line) == 21 || day == days {
+			fmt.Fprintf(p.Stdout, "%s\n", strings.TrimRight(line, " "))
+			line = ""
+		}
+	}
+	return 0
 }
@@ -0,0 +1,89 @@
+//
+// cmd_date_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	saved := uptime
+	defer func() {
+		uptime = saved
+	}()
+	tests := []struct {
+		d      time.Duration
+		stdout string
+	}{
+		{5 * time.Second, "up 00:00:05\n"},
+		{90*time.Minute + 1500*time.Millisecond, "up 01:30:01\n"},
+		{25 * time.Hour, "up 1 day, 01:00:00\n"},
+		{50*time.Hour + 3*time.Minute, "up 2 days, 02:03:00\n"},
+	}
+	for _, test := range tests {
+		d := test.d
+		uptime = func() (time.Duration, error) {
+			return d, nil
+		}
+		stdout, _, status, err := EvalCapture(new(Process), "uptime")
+		if err != nil || status != 0 {
+			t.Fatalf("uptime failed: %v, %v", status, err)
+		}
+		if stdout != test.stdout {
+			t.Errorf("%v: got %q, expected %q", test.d, stdout, test.stdout)
+		}
+	}
+}
+
+func TestCal(t *testing.T) {
+	stdout, _, status, err := EvalCapture(new(Process), "cal 2 2021")
+	if err != nil || status != 0 {
+		t.Fatalf("cal failed: %v, %v", status, err)
+	}
+	expected := `   February 2021
+Su Mo Tu We Th Fr Sa
+    1  2  3  4  5  6
+ 7  8  9 10 11 12 13
+14 15 16 17 18 19 20
+21 22 23 24 25 26 27
+28
+`
+	if stdout != expected {
+		t.Errorf("cal: got\n%s\nexpected\n%s", stdout, expected)
+	}
+
+	tests := []struct {
+		args string
+		last string
+	}{
+		{"1 2021", "31"},
+		{"2 2020", "29"},
+		{"2 2100", "28"},
+		{"4 2021", "30"},
+		{"12 1999", "31"},
+	}
+	for _, test := range tests {
+		stdout, _, _, err := EvalCapture(new(Process), "cal "+test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := strings.Fields(stdout)
+		if fields[len(fields)-1] != test.last {
+			t.Errorf("cal %s: last day %s, expected %s",
+				test.args, fields[len(fields)-1], test.last)
+		}
+	}
+
+	_, stderr, status, _ := EvalCapture(new(Process), "cal 13")
+	if status != 1 || stderr != "cal: invalid month: 13\n" {
+		t.Errorf("cal 13: got %v, %q", status, stderr)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 
 	"github.com/markkurossi/blackbox-os/lib/bbos"
 	"github.com/markkurossi/blackbox-os/lib/readline"
@@ -21,90 +20,129 @@ import (
 func init() {
 	builtin = append(builtin, []Builtin{
 		Builtin{
-			Name: "pwd",
-			Cmd:  cmd_pwd,
+			Name:  "pwd",
+			Usage: "pwd",
+			Help:  "Print the working directory.",
+			Cmd:   cmd_pwd,
 		},
 		Builtin{
-			Name: "cd",
-			Cmd:  cmd_cd,
+			Name:  "cd",
+			Usage: "cd [dir | -]",
+			Help:  "Change the working directory.",
+			Cmd:   cmd_cd,
 		},
 		Builtin{
-			Name: "ls",
-			Cmd:  cmd_ls,
+			Name:  "ls",
+			Usage: "ls [file...]",
+			Help:  "List directory contents.",
+			Cmd:   cmd_ls,
 		},
 		Builtin{
-			Name: "cat",
-			Cmd:  cmd_cat,
+			Name:  "cat",
+			Usage: "cat [file...]",
+			Help:  "Concatenate files to the standard output.",
+			Cmd:   cmd_cat,
 		},
 	}...)
 }
 
-func cmd_pwd(args []string) {
-	str, err := bbos.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "pwd: %s\n", err)
-	} else {
-		fmt.Printf("%s\n", str)
+// The working directory functions.
+var (
+	chdir = bbos.Chdir
+	getwd = bbos.Getwd
+)
+
+func cmd_pwd(p *Process, args []string) int {
+	if len(p.WorkingDir) == 0 {
+		fmt.Fprintf(p.Stderr, "pwd: working directory unknown\n")
+		return 1
 	}
+	fmt.Fprintf(p.Stdout, "%s\n", p.WorkingDir)
+	return 0
 }
 
-func cmd_cd(args []string) {
-	var err error
+// cmd_cd changes the working directory. Without arguments, it changes
+// to the home directory $HOME and the argument - changes to the
+// previous directory $OLDPWD.
+func cmd_cd(p *Process, args []string) int {
+	var dir string
 	if len(args) < 2 {
-		err = bbos.Chdir("/")
+		dir = p.Getenv("HOME")
+		if len(dir) == 0 {
+			dir = "/"
+		}
+	} else if args[1] == "-" {
+		dir = p.Getenv("OLDPWD")
+		if len(dir) == 0 {
+			fmt.Fprintf(p.Stderr, "cd: OLDPWD not set\n")
+			return 1
+		}
 	} else {
-		err = bbos.Chdir(args[1])
+		dir = args[1]
+	}
+	err := chdir(p.Path(dir))
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "cd: %s: %s\n", dir, err)
+		return 1
 	}
+	wd, err := getwd()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cd: %s\n", err)
+		fmt.Fprintf(p.Stderr, "cd: %s\n", err)
+		return 1
 	}
+	if len(p.WorkingDir) > 0 {
+		p.Setenv("OLDPWD", p.WorkingDir)
+	}
+	p.Setenv("PWD", wd)
+	p.WorkingDir = wd
+
+	if len(args) > 1 && args[1] == "-" {
+		fmt.Fprintf(p.Stdout, "%s\n", wd)
+	}
+	return 0
 }
 
-func cmd_ls(args []string) {
+func cmd_ls(p *Process, args []string) int {
 	args = args[1:]
 	switch len(args) {
 	case 0:
-		ls(".")
+		return ls(p, ".")
 
 	case 1:
-		ls(args[0])
+		return ls(p, args[0])
 
 	default:
+		var status int
 		for idx, arg := range args {
 			if idx > 0 {
-				fmt.Println()
+				fmt.Fprintln(p.Stdout)
+			}
+			fmt.Fprintf(p.Stdout, "%s:\n", arg)
+			if ls(p, arg) != 0 {
+				status = 1
 			}
-			fmt.Printf("%s:\n", arg)
-			ls(arg)
 		}
+		return status
 	}
 }
 
-func ls(dir string) {
-	files, err := ioutil.ReadDir(dir)
+func ls(p *Process, dir string) int {
+	files, err := ioutil.ReadDir(p.Path(dir))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ls: %s\n", err)
-		return
+		fmt.Fprintf(p.Stderr, "ls: %s\n", err)
+		return 1
 	}
 	var names []string
 	for _, f := range files {
 		names = append(names, f.Name())
 	}
-	readline.Tabulate(names, os.Stdout)
+	readline.Tabulate(names, p.Stdout)
+	return 0
 }
 
-func cmd_cat(args []string) {
-	for i := 1; i < len(args); i++ {
-		file, err := os.Open(args[i])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "cat: %s: %s\n", args[i], err)
-			continue
-		}
-		defer file.Close()
-
-		_, err = io.Copy(os.Stdout, file)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "cat: %s: %s\n", args[i], err)
-		}
-	}
+func cmd_cat(p *Process, args []string) int {
+	return readInputs(p, "cat", args[1:], func(in io.Reader) error {
+		_, err := io.Copy(p.Stdout, in)
+		return err
+	})
 }
@@ -0,0 +1,106 @@
+//
+// cmd_filesystem_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCd(t *testing.T) {
+	savedChdir, savedGetwd := chdir, getwd
+	chdir, getwd = os.Chdir, os.Getwd
+	defer func() {
+		chdir, getwd = savedChdir, savedGetwd
+	}()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("OLDPWD", os.Getenv("OLDPWD"))
+	defer os.Setenv("PWD", os.Getenv("PWD"))
+
+	tmp, err := ioutil.TempDir("", "cd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tmp, err = filepath.EvalSymlinks(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := path.Join(tmp, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(sub, "file"), []byte("data\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("HOME", sub)
+
+	tests := []struct {
+		script string
+		stdout string
+		stderr string
+		status int
+	}{
+		{
+			script: "cd sub\npwd\ncat file\ntest -f file",
+			stdout: sub + "\ndata\n",
+		},
+		{
+			script: "cd sub\ncd -\npwd",
+			stdout: tmp + "\n" + tmp + "\n",
+		},
+		{
+			script: "cd nosuch\npwd",
+			stdout: tmp + "\n",
+			stderr: "cd: nosuch: ",
+		},
+		{
+			script: "cd\npwd",
+			stdout: sub + "\n",
+		},
+	}
+	for _, test := range tests {
+		if err := os.Chdir(tmp); err != nil {
+			t.Fatal(err)
+		}
+		os.Unsetenv("OLDPWD")
+		p := &Process{
+			Stdin:      strings.NewReader(""),
+			WorkingDir: tmp,
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if !strings.HasPrefix(stderr, test.stderr) ||
+			(len(test.stderr) == 0 && len(stderr) > 0) {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
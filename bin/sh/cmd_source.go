@@ -0,0 +1,58 @@
+//
+// cmd_source.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "source",
+			Usage: "source file [arg...]",
+			Help:  "Run the commands of file in this shell.",
+			Cmd:   cmd_source,
+		},
+		Builtin{
+			Name:  ".",
+			Usage: ". file [arg...]",
+			Help:  "Run the commands of file in this shell.",
+			Cmd:   cmd_source,
+		},
+	}...)
+}
+
+// cmd_source evaluates the script file in the current shell. The
+// optional arguments are set as the script's positional parameters.
+func cmd_source(p *Process, args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintf(p.Stderr, "usage: %s file [arg...]\n", args[0])
+		return 2
+	}
+	data, err := ioutil.ReadFile(p.Path(args[1]))
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "%s: %s\n", args[0], err)
+		return 1
+	}
+	sp := *p
+	if len(args) > 2 {
+		sp.Args = args[1:]
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	status, err := evalLines(&sp, lines)
+	p.WorkingDir = sp.WorkingDir
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "%s: %s\n", args[1], err)
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,68 @@
+//
+// cmd_source_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	scripts := map[string]string{
+		"count.sh": "test $# -eq 3\n",
+		"args.sh": `test $# -eq 3
+test "$2" = "b c"
+source count.sh "$@"
+`,
+	}
+	for name, script := range scripts {
+		err = ioutil.WriteFile(path.Join(dir, name), []byte(script), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		script string
+		status int
+	}{
+		{`source args.sh a "b c" d`, 0},
+		{`source args.sh a b c d`, 1},
+		{`source args.sh a "b c" d e`, 1},
+		{`. count.sh 1 2 3`, 0},
+		{`source count.sh`, 1},
+		{`source count.sh $@`, 1},
+		{`source count.sh a "" b`, 0},
+	}
+	for _, test := range tests {
+		p := &Process{
+			Stdin:      strings.NewReader(""),
+			WorkingDir: dir,
+			Args:       []string{"sh"},
+		}
+		_, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v (%s)",
+				test.script, status, test.status, stderr)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+//
+// cmd_term.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "reset",
+			Usage: "reset",
+			Help:  "Reset the terminal.",
+			Cmd:   cmd_reset,
+		},
+		Builtin{
+			Name:  "tput",
+			Usage: "tput cols|lines",
+			Help:  "Print the terminal width or height.",
+			Cmd:   cmd_tput,
+		},
+	}...)
+}
+
+// winSize queries the terminal size from the kernel.
+var winSize = func() (cols, rows int, err error) {
+	return bbos.GetWinSize(1)
+}
+
+// WinSize returns the terminal size in columns and rows. The size is
+// queried on each call so it follows the terminal resizes. If the
+// terminal size is not available, the size is taken from the COLUMNS
+// and LINES environment variables, defaulting to 80x24.
+func (p *Process) WinSize() (cols, rows int) {
+	cols, rows, err := winSize()
+	if err == nil && cols > 0 && rows > 0 {
+		return cols, rows
+	}
+	return envSize(p, "COLUMNS", 80), envSize(p, "LINES", 24)
+}
+
+func envSize(p *Process, name string, def int) int {
+	val, err := strconv.Atoi(p.Getenv(name))
+	if err != nil || val <= 0 {
+		return def
+	}
+	return val
+}
+
+// resetSequence resets the terminal to its initial state (RIS),
+// clears the scrollback buffer, restores the cursor visibility,
+// autowrap, and character attributes, and clears the screen.
+const resetSequence = "\x1bc\x1b[3J\x1b[?25h\x1b[?7h\x1b[0m\x1b[H\x1b[2J"
+
+func cmd_reset(p *Process, args []string) int {
+	if _, err := fmt.Fprint(p.Stdout, resetSequence); err != nil {
+		return writeError(p, "reset", err)
+	}
+	return 0
+}
+
+// cmd_tput prints the terminal capabilities. The supported
+// capabilities are cols and lines.
+func cmd_tput(p *Process, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(p.Stderr, "usage: tput cols|lines\n")
+		return 2
+	}
+	cols, rows := p.WinSize()
+	var val int
+	switch args[1] {
+	case "cols":
+		val = cols
+	case "lines":
+		val = rows
+	default:
+		fmt.Fprintf(p.Stderr, "tput: unknown terminal capability '%s'\n",
+			args[1])
+		return 4
+	}
+	if _, err := fmt.Fprintf(p.Stdout, "%d\n", val); err != nil {
+		return writeError(p, "tput", err)
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+//
+// cmd_term_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/vt100"
+)
+
+func newEmulator() (*vt100.Emulator, *vt100.Display) {
+	display := vt100.NewDisplay(80, 24)
+	return vt100.NewEmulator(ioutil.Discard, ioutil.Discard, display), display
+}
+
+func TestReset(t *testing.T) {
+	var stdout bytes.Buffer
+	p := &Process{
+		Stdin:  strings.NewReader(""),
+		Stdout: &stdout,
+		Stderr: new(bytes.Buffer),
+	}
+	status, err := runCommand(p, []string{"reset"})
+	if err != nil || status != 0 {
+		t.Fatalf("reset: status %d, err %v", status, err)
+	}
+	if stdout.String() != resetSequence {
+		t.Errorf("reset: got %q, expected %q", stdout.String(), resetSequence)
+	}
+
+	// Leave the terminal in a bad state and reset it.
+	emulator, display := newEmulator()
+	input := "\x1b[5;10r\x1b[?6h\x1b[1;31mHello\x1b[20;40Hworld\n" +
+		stdout.String()
+	for _, r := range input {
+		emulator.Input(int(r))
+	}
+
+	pristine, pristineDisplay := newEmulator()
+	if !reflect.DeepEqual(display.Lines, pristineDisplay.Lines) {
+		t.Errorf("reset: display not cleared")
+	}
+	if !reflect.DeepEqual(emulator, pristine) {
+		t.Errorf("reset: got %+v, expected %+v", emulator, pristine)
+	}
+}
+
+func TestTput(t *testing.T) {
+	cols, rows := 100, 30
+	saved := winSize
+	winSize = func() (int, int, error) {
+		return cols, rows, nil
+	}
+	defer func() {
+		winSize = saved
+	}()
+
+	tests := []struct {
+		script string
+		stdout string
+		status int
+	}{
+		{"tput cols", "100\n", 0},
+		{"tput lines", "30\n", 0},
+		{"tput colors", "", 4},
+		{"tput", "", 2},
+	}
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range tests {
+		stdout, _, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout || status != test.status {
+			t.Errorf("%q: got %q (%d), expected %q (%d)",
+				test.script, stdout, status, test.stdout, test.status)
+		}
+	}
+
+	// Resize the terminal.
+	cols, rows = 132, 43
+	if c, r := p.WinSize(); c != cols || r != rows {
+		t.Errorf("WinSize after resize: got %dx%d, expected %dx%d",
+			c, r, cols, rows)
+	}
+}
@@ -0,0 +1,547 @@
+//
+// cmd_text.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/markkurossi/vt100"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "fold",
+			Usage: "fold [-w width] [file...]",
+			Help:  "Wrap the input lines to fit the width.",
+			Cmd:   cmd_fold,
+		},
+		Builtin{
+			Name:  "columns",
+			Usage: "columns [file...]",
+			Help:  "Print the display width of the input.",
+			Cmd:   cmd_columns,
+		},
+		Builtin{
+			Name:  "cut",
+			Usage: "cut -c list | -f list [-d delim] [file...]",
+			Help:  "Print the selected parts of the input lines.",
+			Cmd:   cmd_cut,
+		},
+		Builtin{
+			Name:  "head",
+			Usage: "head [-n count] [file...]",
+			Help:  "Print the first lines of the input.",
+			Cmd:   cmd_head,
+		},
+		Builtin{
+			Name:  "yes",
+			Usage: "yes [string...]",
+			Help:  "Print string, or y, repeatedly.",
+			Cmd:   cmd_yes,
+		},
+		Builtin{
+			Name:  "tac",
+			Usage: "tac [file...]",
+			Help:  "Print the input lines in reverse order.",
+			Cmd:   cmd_tac,
+		},
+		Builtin{
+			Name:  "rev",
+			Usage: "rev [file...]",
+			Help:  "Reverse the characters of the input lines.",
+			Cmd:   cmd_rev,
+		},
+		Builtin{
+			Name: "nl",
+			Usage: "nl [-b a|t] [-n ln|rn|rz] [-s sep] [-v start]" +
+				" [-w width] [file...]",
+			Help: "Number the input lines.",
+			Cmd:  cmd_nl,
+		},
+	}...)
+}
+
+// readInputs calls the function f for each named input file, or for
+// the standard input if no files are given. It returns the exit
+// status 1 if any of the inputs failed and 0 otherwise. A broken
+// pipe terminates the processing with StatusBrokenPipe.
+func readInputs(p *Process, name string, files []string,
+	f func(in io.Reader) error) int {
+
+	if len(files) == 0 {
+		err := f(p.Stdin)
+		if err != nil {
+			return writeError(p, name, err)
+		}
+		return 0
+	}
+	var status int
+	for _, arg := range files {
+		file, err := os.Open(p.Path(arg))
+		if err != nil {
+			fmt.Fprintf(p.Stderr, "%s: %s: %s\n", name, arg, err)
+			status = 1
+			continue
+		}
+		err = f(file)
+		file.Close()
+		if errors.Is(err, ErrBrokenPipe) {
+			return StatusBrokenPipe
+		}
+		if err != nil {
+			fmt.Fprintf(p.Stderr, "%s: %s: %s\n", name, arg, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func cmd_fold(p *Process, args []string) int {
+	width := p.Flags.Int("w", 80, "Fold lines to `width` display columns.")
+	if err := p.Flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *width <= 0 {
+		fmt.Fprintf(p.Stderr, "fold: invalid width: %d\n", *width)
+		return 2
+	}
+	// Each fold has its own width cache since the pipeline stages run
+	// concurrently.
+	widths := make(runeWidths)
+	return readInputs(p, "fold", p.Flags.Args(), func(in io.Reader) error {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadString('\n')
+			if len(line) > 0 {
+				nl := strings.HasSuffix(line, "\n")
+				line = fold(strings.TrimSuffix(line, "\n"), *width, widths)
+				if nl {
+					line += "\n"
+				}
+				if _, err := fmt.Fprint(p.Stdout, line); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// fold folds the line to the display width. The escape sequences do
+// not take any space on the display and they are never split.
+func fold(line string, width int, widths runeWidths) string {
+	var sb strings.Builder
+	var col int
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		n := escapeLength(runes[i:])
+		if n > 0 {
+			sb.WriteString(string(runes[i : i+n]))
+			i += n
+			continue
+		}
+		w := widths.width(runes[i])
+		if col > 0 && col+w > width {
+			sb.WriteByte('\n')
+			col = 0
+		}
+		sb.WriteRune(runes[i])
+		col += w
+		i++
+	}
+	return sb.String()
+}
+
+// escapeLength returns the length of the escape sequence starting at
+// the beginning of the input, or 0 if the input does not start with
+// an escape sequence.
+func escapeLength(input []rune) int {
+	if len(input) == 0 || input[0] != 0x1b {
+		return 0
+	}
+	if len(input) == 1 {
+		return 1
+	}
+	switch input[1] {
+	case '[':
+		// CSI: parameters and intermediates up to the final byte.
+		for i := 2; i < len(input); i++ {
+			if input[i] >= 0x40 && input[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(input)
+
+	case ']':
+		// OSC: terminated by BEL or ST.
+		for i := 2; i < len(input); i++ {
+			if input[i] == 0x07 {
+				return i + 1
+			}
+			if input[i] == 0x1b && i+1 < len(input) && input[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(input)
+
+	default:
+		return 2
+	}
+}
+
+// runeWidths caches the display widths of runes.
+type runeWidths map[rune]int
+
+// width returns the display width of the rune as measured by the
+// terminal emulator.
+func (widths runeWidths) width(r rune) int {
+	w, ok := widths[r]
+	if !ok {
+		var err error
+		w, _, err = vt100.DisplayWidth(string(r))
+		if err != nil {
+			w = 1
+		}
+		widths[r] = w
+	}
+	return w
+}
+
+func cmd_columns(p *Process, args []string) int {
+	return readInputs(p, "columns", args[1:], func(in io.Reader) error {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		width, _, err := vt100.DisplayWidth(string(data))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(p.Stdout, "%d\n", width)
+		return nil
+	})
+}
+
+// forEachLine calls the function f for each input line. The line
+// does not contain the trailing newline. The processing stops at the
+// first error that f returns.
+func forEachLine(in io.Reader, f func(line string) error) error {
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			ferr := f(strings.TrimSuffix(line, "\n"))
+			if ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// splitOptionValues splits the values attached to the single-letter
+// options, such as -f1,3 and -d, into separate arguments for the flag
+// package. The opts lists the options that take values. The
+// splitting stops at the first argument that is not an option.
+func splitOptionValues(args []string, opts string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(result, args[i:]...)
+		}
+		if arg[1] == '-' || strings.IndexByte(opts, arg[1]) < 0 {
+			result = append(result, arg)
+		} else if len(arg) > 2 {
+			result = append(result, arg[:2], arg[2:])
+		} else if i+1 < len(args) {
+			// The value is the next argument.
+			result = append(result, arg, args[i+1])
+			i++
+		} else {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func cmd_cut(p *Process, args []string) int {
+	fields := p.Flags.String("f", "", "Select fields `list`.")
+	chars := p.Flags.String("c", "", "Select characters `list`.")
+	delim := p.Flags.String("d", "\t", "Field `delimiter`.")
+	outDelim := p.Flags.String("output-delimiter", "",
+		"Output field `delimiter`, defaults to the input delimiter.")
+	if err := p.Flags.Parse(splitOptionValues(args[1:], "fcd")); err != nil {
+		return 2
+	}
+	if (len(*fields) == 0) == (len(*chars) == 0) {
+		fmt.Fprintf(p.Stderr, "cut: specify exactly one of -f or -c\n")
+		return 2
+	}
+	if len([]rune(*delim)) != 1 {
+		fmt.Fprintf(p.Stderr, "cut: the delimiter must be a single character\n")
+		return 2
+	}
+	sep := *delim
+	p.Flags.Visit(func(f *flag.Flag) {
+		if f.Name == "output-delimiter" {
+			sep = *outDelim
+		}
+	})
+
+	list := *fields
+	if len(list) == 0 {
+		list = *chars
+	}
+	ranges, err := parseRanges(list)
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "cut: %s\n", err)
+		return 2
+	}
+
+	return readInputs(p, "cut", p.Flags.Args(), func(in io.Reader) error {
+		return forEachLine(in, func(line string) error {
+			if len(*chars) > 0 {
+				var result []rune
+				for idx, r := range []rune(line) {
+					if ranges.contains(idx + 1) {
+						result = append(result, r)
+					}
+				}
+				_, err := fmt.Fprintf(p.Stdout, "%s\n", string(result))
+				return err
+			}
+			parts := strings.Split(line, *delim)
+			if len(parts) == 1 {
+				// Lines without delimiters are printed as-is.
+				_, err := fmt.Fprintf(p.Stdout, "%s\n", line)
+				return err
+			}
+			var result []string
+			for idx, part := range parts {
+				if ranges.contains(idx + 1) {
+					result = append(result, part)
+				}
+			}
+			_, err := fmt.Fprintf(p.Stdout, "%s\n", strings.Join(result, sep))
+			return err
+		})
+	})
+}
+
+// Ranges define a list of 1-based position ranges.
+type Ranges []Range
+
+// Range defines an inclusive position range. The zero To means that
+// the range extends to the end of the line.
+type Range struct {
+	From int
+	To   int
+}
+
+func (ranges Ranges) contains(pos int) bool {
+	for _, r := range ranges {
+		if pos >= r.From && (r.To == 0 || pos <= r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRanges parses the comma-separated list of positions and
+// ranges: N, N-M, N-, and -M.
+func parseRanges(list string) (Ranges, error) {
+	var result Ranges
+	for _, item := range strings.Split(list, ",") {
+		var r Range
+		var err error
+
+		idx := strings.IndexByte(item, '-')
+		if idx < 0 {
+			r.From, err = parsePosition(item)
+			r.To = r.From
+		} else {
+			if idx == 0 && len(item) == 1 {
+				return nil, fmt.Errorf("invalid range: %s", item)
+			}
+			r.From = 1
+			if idx > 0 {
+				r.From, err = parsePosition(item[:idx])
+			}
+			if err == nil && idx+1 < len(item) {
+				r.To, err = parsePosition(item[idx+1:])
+			}
+			if err == nil && r.To != 0 && r.To < r.From {
+				err = fmt.Errorf("invalid decreasing range: %s", item)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func parsePosition(val string) (int, error) {
+	pos, err := strconv.Atoi(val)
+	if err != nil || pos <= 0 {
+		return 0, fmt.Errorf("invalid position: %s", val)
+	}
+	return pos, nil
+}
+
+func cmd_head(p *Process, args []string) int {
+	count := p.Flags.Int("n", 10, "Print the first `count` lines.")
+	if err := p.Flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *count < 0 {
+		fmt.Fprintf(p.Stderr, "head: invalid line count: %d\n", *count)
+		return 2
+	}
+	return readInputs(p, "head", p.Flags.Args(), func(in io.Reader) error {
+		r := bufio.NewReader(in)
+		for i := 0; i < *count; i++ {
+			line, err := r.ReadString('\n')
+			if len(line) > 0 {
+				if _, werr := io.WriteString(p.Stdout, line); werr != nil {
+					return werr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func cmd_yes(p *Process, args []string) int {
+	line := "y\n"
+	if len(args) > 1 {
+		line = strings.Join(args[1:], " ") + "\n"
+	}
+	for {
+		select {
+		case <-p.Interrupt.Done():
+			return p.Interrupt.Signalled().ExitStatus()
+		default:
+		}
+		if _, err := io.WriteString(p.Stdout, line); err != nil {
+			return writeError(p, "yes", err)
+		}
+	}
+}
+
+// cmd_tac prints the lines of each input in reverse order.
+func cmd_tac(p *Process, args []string) int {
+	return readInputs(p, "tac", args[1:], func(in io.Reader) error {
+		var lines []string
+		err := forEachLine(in, func(line string) error {
+			lines = append(lines, line)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if _, err := fmt.Fprintf(p.Stdout, "%s\n", lines[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// cmd_rev reverses the characters of each input line.
+func cmd_rev(p *Process, args []string) int {
+	return readInputs(p, "rev", args[1:], func(in io.Reader) error {
+		return forEachLine(in, func(line string) error {
+			runes := []rune(line)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			_, err := fmt.Fprintf(p.Stdout, "%s\n", string(runes))
+			return err
+		})
+	})
+}
+
+// cmd_nl numbers the input lines. The numbering continues across the
+// input files.
+func cmd_nl(p *Process, args []string) int {
+	body := p.Flags.String("b", "t",
+		"Number `style`: a for all lines, t for non-empty lines.")
+	format := p.Flags.String("n", "rn",
+		"Number `format`: ln left, rn right, rz right with zeros.")
+	sep := p.Flags.String("s", "\t", "Separator `string` after numbers.")
+	start := p.Flags.Int("v", 1, "First line `number`.")
+	width := p.Flags.Int("w", 6, "Line number `width`.")
+	if err := p.Flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *body != "a" && *body != "t" {
+		fmt.Fprintf(p.Stderr, "nl: invalid body numbering style: %s\n",
+			*body)
+		return 2
+	}
+	var numFormat string
+	switch *format {
+	case "ln":
+		numFormat = "%-*d"
+	case "rn":
+		numFormat = "%*d"
+	case "rz":
+		numFormat = "%0*d"
+	default:
+		fmt.Fprintf(p.Stderr, "nl: invalid line number format: %s\n",
+			*format)
+		return 2
+	}
+	if *width <= 0 {
+		fmt.Fprintf(p.Stderr, "nl: invalid line number width: %d\n", *width)
+		return 2
+	}
+	blank := strings.Repeat(" ", *width+len(*sep))
+
+	num := *start
+	return readInputs(p, "nl", p.Flags.Args(), func(in io.Reader) error {
+		return forEachLine(in, func(line string) error {
+			if *body == "t" && len(line) == 0 {
+				_, err := fmt.Fprintf(p.Stdout, "%s\n", blank)
+				return err
+			}
+			_, err := fmt.Fprintf(p.Stdout, numFormat+"%s%s\n",
+				*width, num, *sep, line)
+			num++
+			return err
+		})
+	})
+}
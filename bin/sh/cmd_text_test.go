@@ -0,0 +1,142 @@
+//
+// cmd_text_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var textErrorTests = []string{
+	"cut -f 1 -c 1",
+	"cut",
+	"cut -f 3-1",
+	"cut -f 0",
+	"cut -d ab -f 1",
+	"nl -b x",
+	"nl -n xx",
+	"nl -w 0",
+}
+
+var textTests = []struct {
+	script string
+	stdout string
+}{
+	{
+		script: "fold -w 5 <<EOF\nabcdefghijkl\nEOF\n",
+		stdout: "abcde\nfghij\nkl\n",
+	},
+	{
+		script: "fold -w 5 <<EOF\n\x1b[1mabc\x1b[0mdefgh\nEOF\n",
+		stdout: "\x1b[1mabc\x1b[0mde\nfgh\n",
+	},
+	{
+		script: "fold -w 4 <<EOF\nab\x1b]0;title\x07cdef\nEOF\n",
+		stdout: "ab\x1b]0;title\x07cd\nef\n",
+	},
+	{
+		// The console emulator renders each rune in one cell.
+		script: "fold -w 3 <<EOF\n日本語の文\nEOF\n",
+		stdout: "日本語\nの文\n",
+	},
+	{
+		script: "cut -d : -f 1,3 <<EOF\n" +
+			"root:x:0:0\nuser:x:1000:1000\nnofields\nEOF\n",
+		stdout: "root:0\nuser:1000\nnofields\n",
+	},
+	{
+		script: "cut -d , -f 2-3,5- --output-delimiter=';' <<EOF\n" +
+			"a,b,c,d,e,f\nEOF\n",
+		stdout: "b;c;e;f\n",
+	},
+	{
+		script: "cut -d , -f -2 <<EOF\na,b,c\nEOF\n",
+		stdout: "a,b\n",
+	},
+	{
+		script: "cut -c 1-3,6 <<EOF\nabcdefgh\näöåxyz\nEOF\n",
+		stdout: "abcf\näöåz\n",
+	},
+	{
+		script: "cut -c 4- <<EOF\nabcdefgh\nEOF\n",
+		stdout: "defgh\n",
+	},
+	{
+		script: "cut -d, -f1,3-5 <<EOF\na,b,c,d,e,f\nEOF\n",
+		stdout: "a,c,d,e\n",
+	},
+	{
+		script: "cut -f2 -d - <<EOF\na-b\nEOF\n",
+		stdout: "b\n",
+	},
+	{
+		script: "cut -c2- <<EOF\nabc\nEOF\n",
+		stdout: "bc\n",
+	},
+	{
+		script: "columns <<EOF\n\x1b[31mred\x1b[0m text\nEOF\n",
+		stdout: "8\n",
+	},
+	{
+		script: "tac <<EOF\nfirst\nsecond\nthird\nEOF\n",
+		stdout: "third\nsecond\nfirst\n",
+	},
+	{
+		script: "rev <<EOF\nabc\näöå 日本\nEOF\n",
+		stdout: "cba\n本日 åöä\n",
+	},
+	{
+		script: "nl <<EOF\nfirst\n\nthird\nEOF\n",
+		stdout: "     1\tfirst\n       \n     2\tthird\n",
+	},
+	{
+		script: "nl -b a -n rz -w 3 -s : -v 9 <<EOF\na\n\nc\nEOF\n",
+		stdout: "009:a\n010:\n011:c\n",
+	},
+	{
+		script: "nl -n ln -w 3 -s \" \" <<EOF\na\nEOF\n",
+		stdout: "1   a\n",
+	},
+}
+
+func TestTextCommands(t *testing.T) {
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range textTests {
+		stdout, stderr, _, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: %s", test.script, err)
+			continue
+		}
+		if stderr != "" {
+			t.Errorf("%q: stderr: %s", test.script, stderr)
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: got %q, expected %q", test.script, stdout,
+				test.stdout)
+		}
+	}
+}
+
+func TestTextCommandErrors(t *testing.T) {
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, script := range textErrorTests {
+		_, stderr, status, err := EvalCapture(p, script)
+		if err != nil {
+			t.Errorf("%q: %s", script, err)
+			continue
+		}
+		if status == 0 || len(stderr) == 0 {
+			t.Errorf("%q: expected an error, got status %d", script, status)
+		}
+	}
+}
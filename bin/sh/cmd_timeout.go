@@ -0,0 +1,109 @@
+//
+// cmd_timeout.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "timeout",
+			Usage: "timeout duration command [arg...]",
+			Help:  "Run command and terminate it after duration.",
+			Cmd:   cmd_timeout,
+		},
+	}...)
+}
+
+// Timeout exit statuses.
+const (
+	StatusTimeout      = 124
+	StatusTimeoutError = 125
+)
+
+// parseDuration parses the duration as a number of seconds with an
+// optional s, m, h, or d unit suffix.
+func parseDuration(arg string) (time.Duration, bool) {
+	unit := time.Second
+	switch {
+	case strings.HasSuffix(arg, "s"):
+	case strings.HasSuffix(arg, "m"):
+		unit = time.Minute
+	case strings.HasSuffix(arg, "h"):
+		unit = time.Hour
+	case strings.HasSuffix(arg, "d"):
+		unit = 24 * time.Hour
+	default:
+		arg += "s"
+	}
+	val, err := strconv.ParseFloat(arg[:len(arg)-1], 64)
+	if err != nil || val < 0 {
+		return 0, false
+	}
+	return time.Duration(val * float64(unit)), true
+}
+
+// cmd_timeout runs the command and sends it SIGTERM if it does not
+// complete within the duration. The duration 0 disables the
+// timeout. The signals delivered to the timeout command are passed to
+// the command.
+func cmd_timeout(p *Process, args []string) int {
+	if len(args) < 3 {
+		fmt.Fprintf(p.Stderr, "usage: timeout duration command [arg...]\n")
+		return StatusTimeoutError
+	}
+	d, ok := parseDuration(args[1])
+	if !ok {
+		fmt.Fprintf(p.Stderr, "timeout: invalid time interval: %s\n",
+			args[1])
+		return StatusTimeoutError
+	}
+
+	cp := *p
+	cp.Interrupt = NewInterrupt()
+
+	var timer <-chan time.Time
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timer = t.C
+	}
+	done := make(chan struct{})
+	result := make(chan bool)
+	go func() {
+		select {
+		case <-timer:
+			cp.Interrupt.Signal(SIGTERM)
+			<-done
+			result <- true
+		case <-p.Interrupt.Done():
+			cp.Interrupt.Signal(p.Interrupt.Signalled())
+			<-done
+			result <- false
+		case <-done:
+			result <- false
+		}
+	}()
+
+	status, err := runCommand(&cp, args[2:])
+	close(done)
+	if <-result {
+		return StatusTimeout
+	}
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "timeout: %s: %s\n", args[2], err)
+		return StatusTimeoutError
+	}
+	return status
+}
@@ -0,0 +1,158 @@
+//
+// cmd_timeout_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var timeoutTests = []struct {
+	script string
+	stdout string
+	stderr string
+	status int
+}{
+	{
+		script: "timeout 5 true",
+	},
+	{
+		script: "timeout 5s false",
+		status: 1,
+	},
+	{
+		script: "timeout 1m yes a | head -n 2",
+		stdout: "a\na\n",
+	},
+	{
+		script: "timeout 0.05 sleep 10",
+		status: StatusTimeout,
+	},
+	{
+		script: "timeout 0.05 sleep 10 || yes $? | head -n 1",
+		stdout: "124\n",
+	},
+	{
+		script: "timeout 0.05 yes > /dev/null",
+		status: StatusTimeout,
+	},
+	{
+		script: "timeout 0 sleep 0.01",
+	},
+	{
+		script: "timeout x true",
+		stderr: "timeout: invalid time interval: x\n",
+		status: StatusTimeoutError,
+	},
+	{
+		script: "timeout 1",
+		stderr: "usage: timeout duration command [arg...]\n",
+		status: StatusTimeoutError,
+	},
+}
+
+func TestTimeout(t *testing.T) {
+	for _, test := range timeoutTests {
+		p := &Process{
+			Stdin: strings.NewReader(""),
+			Env:   make(map[string]string),
+		}
+		start := time.Now()
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%q: took %v", test.script, elapsed)
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
+
+func TestTimeoutInterrupt(t *testing.T) {
+	p := &Process{
+		Stdin:     strings.NewReader(""),
+		Env:       make(map[string]string),
+		Interrupt: NewInterrupt(),
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.Interrupt.Signal(SIGINT)
+	}()
+	_, _, status, err := EvalCapture(p, "timeout 10 sleep 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != SIGINT.ExitStatus() {
+		t.Errorf("status %v, expected %v", status, SIGINT.ExitStatus())
+	}
+}
+
+func TestTimeoutProcess(t *testing.T) {
+	savedSpawn, savedWait, savedKill := spawn, wait, kill
+	defer func() {
+		spawn, wait, kill = savedSpawn, savedWait, savedKill
+	}()
+
+	// The process runs until it is killed.
+	killed := make(chan int, 1)
+	spawn = func(argv []string, fds []int) (int, error) {
+		return 7, nil
+	}
+	wait = func(pid int) (int, error) {
+		return 128 + <-killed, nil
+	}
+	var killPID, killSig int
+	kill = func(pid, signal int) error {
+		killPID, killSig = pid, signal
+		killed <- signal
+		return nil
+	}
+
+	// Processes can only be connected to files.
+	out, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	p := &Process{
+		Stdin:  os.Stdin,
+		Stdout: out,
+		Stderr: out,
+		Env:    make(map[string]string),
+	}
+	status, err := evalLines(p, []string{"timeout 0.05 prog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != StatusTimeout {
+		t.Errorf("status %v, expected %v", status, StatusTimeout)
+	}
+	if killPID != 7 || killSig != int(SIGTERM) {
+		t.Errorf("kill(%v, %v), expected kill(7, %v)",
+			killPID, killSig, int(SIGTERM))
+	}
+}
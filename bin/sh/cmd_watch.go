@@ -0,0 +1,63 @@
+//
+// cmd_watch.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	builtin = append(builtin, Builtin{
+		Name:  "watch",
+		Usage: "watch [-n secs] command",
+		Help:  "Run command repeatedly and show its output.",
+		Cmd:   cmd_watch,
+	})
+}
+
+func cmd_watch(p *Process, args []string) int {
+	interval := p.Flags.Float64("n", 2, "Run command every `secs` seconds.")
+	if err := p.Flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if p.Flags.NArg() == 0 {
+		fmt.Fprintf(p.Stderr, "Usage: watch [-n secs] command\n")
+		return 2
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(p.Stderr, "watch: invalid interval: %v\n", *interval)
+		return 2
+	}
+	cmd := append([]string(nil), p.Flags.Args()...)
+	header := fmt.Sprintf("Every %gs: %s", *interval, strings.Join(cmd, " "))
+
+	d := time.Duration(*interval * float64(time.Second))
+	for {
+		// Clear screen and move cursor home.
+		fmt.Fprintf(p.Stdout, "\x1b[H\x1b[2J")
+		fmt.Fprintf(p.Stdout, "%s    %s\n\n",
+			header, time.Now().Format(time.UnixDate))
+
+		_, err := runCommand(p, cmd)
+		if err != nil {
+			fmt.Fprintf(p.Stderr, "%s: %s\n", cmd[0], err)
+		}
+
+		// The terminal stays in the canonical mode so Ctrl-C
+		// reaches watch through the interrupt and the standard input
+		// is left for the commands.
+		select {
+		case <-p.Interrupt.Done():
+			return p.Interrupt.Signalled().ExitStatus()
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//
+// cmd_watch_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	// The standard input is at EOF like in background jobs and watch
+	// does not read it.
+	stdin := strings.NewReader("input")
+	stdout := new(captureBuffer)
+	p := &Process{
+		Stdin:     stdin,
+		Stdout:    stdout,
+		Stderr:    stdout,
+		Interrupt: NewInterrupt(),
+	}
+	done := make(chan struct{})
+	go func() {
+		runCommand(p, []string{"watch", "-n", "0.01", "date"})
+		close(done)
+	}()
+
+	header := "Every 0.01s: date"
+	timeout := time.After(5 * time.Second)
+	for strings.Count(stdout.String(), header) < 2 {
+		select {
+		case <-done:
+			t.Fatalf("watch terminated: %s", stdout.String())
+		case <-timeout:
+			t.Fatalf("watch did not run twice: %s", stdout.String())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	// Ctrl-C stops watch.
+	p.Interrupt.Signal(SIGINT)
+	select {
+	case <-done:
+	case <-timeout:
+		t.Fatalf("watch not interrupted")
+	}
+	if stdin.Len() != len("input") {
+		t.Errorf("watch read %d bytes of input", len("input")-stdin.Len())
+	}
+	runs := strings.Count(stdout.String(), header)
+	if runs < 2 {
+		t.Errorf("watch ran %d times, expected at least 2", runs)
+	}
+	if !strings.Contains(stdout.String(), "\x1b[H\x1b[2J") {
+		t.Errorf("watch did not clear the screen")
+	}
+}
@@ -0,0 +1,72 @@
+//
+// completion_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+var envCompletionTests = []struct {
+	line       string
+	result     string
+	candidates []string
+}{
+	{
+		line:   "echo $HO",
+		result: "echo $HOME",
+	},
+	{
+		line:   "echo ${HO",
+		result: "echo ${HOME}",
+	},
+	{
+		line:       "echo $TEST_COMP",
+		result:     "echo $TEST_COMPLETION_",
+		candidates: []string{"TEST_COMPLETION_A", "TEST_COMPLETION_B"},
+	},
+	{
+		line:       "echo ${TEST_COMPLETION_",
+		result:     "echo ${TEST_COMPLETION_",
+		candidates: []string{"TEST_COMPLETION_A", "TEST_COMPLETION_B"},
+	},
+	{
+		// The process environment is not the shell's variables.
+		line:   "echo $TEST_COMPLETION_C",
+		result: "echo $TEST_COMPLETION_C",
+	},
+	{
+		line:   "echo $NO_SUCH_VARIABLE",
+		result: "echo $NO_SUCH_VARIABLE",
+	},
+}
+
+func TestEnvCompletion(t *testing.T) {
+	os.Setenv("TEST_COMPLETION_C", "c")
+	defer os.Unsetenv("TEST_COMPLETION_C")
+
+	p := &Process{
+		Env: map[string]string{
+			"HOME":              "/",
+			"TEST_COMPLETION_A": "a",
+			"TEST_COMPLETION_B": "b",
+		},
+	}
+	for _, test := range envCompletionTests {
+		result, candidates := tabCompletion(p, test.line)
+		if result != test.result {
+			t.Errorf("%q: got %q, expected %q", test.line, result, test.result)
+		}
+		if !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("%q: candidates %v, expected %v",
+				test.line, candidates, test.candidates)
+		}
+	}
+}
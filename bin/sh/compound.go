@@ -0,0 +1,445 @@
+//
+// compound.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "break",
+			Usage: "break [n]",
+			Help:  "Exit from the n enclosing loops.",
+			Cmd:   cmd_break,
+		},
+		Builtin{
+			Name:  "continue",
+			Usage: "continue [n]",
+			Help:  "Continue the next iteration of the nth enclosing loop.",
+			Cmd:   cmd_continue,
+		},
+	}...)
+}
+
+// loopState holds the state of the running loops.
+type loopState struct {
+	// depth is the nesting depth of the running loops.
+	depth int
+	// breaks is the number of loops that a break or continue exits.
+	breaks int
+	// cont is set if the loop enclosing the exited loops continues
+	// with its next iteration.
+	cont bool
+}
+
+var loop loopState
+
+// isCompound tests if the word starts a compound command.
+func isCompound(word string) bool {
+	switch word {
+	case "if", "while", "until":
+		return true
+	}
+	return false
+}
+
+// isReserved tests if the word is a reserved word that continues or
+// terminates a compound command.
+func isReserved(word string) bool {
+	switch word {
+	case "then", "elif", "else", "fi", "do", "done":
+		return true
+	}
+	return false
+}
+
+// stopped tests if the evaluation of the commands must stop because
+// the shell is exiting, the process has been interrupted, or a break
+// or continue is exiting loops.
+func (p *Process) stopped() bool {
+	return !running || p.interrupted() || loop.breaks > 0
+}
+
+// node defines a parsed command. The simple commands are AND-OR
+// lists and the compound commands contain lists of nodes.
+type node interface {
+	eval(p *Process) (int, error)
+}
+
+// simpleCommand defines an AND-OR list and the here-document lines
+// of its pipelines.
+type simpleCommand struct {
+	list     andOrList
+	heredocs [][]string
+}
+
+func (c *simpleCommand) eval(p *Process) (int, error) {
+	if c.list.background {
+		return startJob(p, c.list, c.heredocs)
+	}
+	return evalAndOr(p, 0, c.list.pipelines, c.heredocs)
+}
+
+// keyword returns the first word of the command and the rest of its
+// first pipeline. The quoted and escaped words and the words with
+// expansions are not keywords.
+func (c *simpleCommand) keyword() (string, string) {
+	line := c.list.pipelines[0].line
+	word, rest := line, ""
+	idx := strings.IndexAny(line, " \t")
+	if idx >= 0 {
+		word, rest = line[:idx], strings.TrimSpace(line[idx+1:])
+	}
+	if strings.ContainsAny(word, "'\"\\$") {
+		return "", line
+	}
+	return word, rest
+}
+
+// terminator checks that the command is only the terminating
+// keyword of a compound command.
+func (c *simpleCommand) terminator() error {
+	_, rest := c.keyword()
+	if len(rest) > 0 {
+		return fmt.Errorf("syntax error near `%s'", rest)
+	}
+	if len(c.list.pipelines) > 1 {
+		return fmt.Errorf("syntax error near `%s'", c.list.pipelines[1].op)
+	}
+	if c.list.background {
+		return fmt.Errorf("background compound commands not supported")
+	}
+	return nil
+}
+
+// ifClause defines a condition and its body. The else clause has an
+// empty condition.
+type ifClause struct {
+	cond []node
+	body []node
+}
+
+// ifCommand implements the if compound command:
+//
+//	if COND; then BODY; elif COND; then BODY; else BODY; fi
+//
+// The conditions and bodies are command lists separated by ; and
+// newlines.
+type ifCommand struct {
+	clauses []*ifClause
+}
+
+func (c *ifCommand) eval(p *Process) (int, error) {
+	for _, clause := range c.clauses {
+		if clause.cond != nil {
+			status, err := evalNodes(p, clause.cond)
+			if err != nil || p.stopped() {
+				return status, err
+			}
+			if status != 0 {
+				continue
+			}
+		}
+		return evalNodes(p, clause.body)
+	}
+	return 0, nil
+}
+
+// loopCommand implements the while and until loops:
+//
+//	while COND; do BODY; done
+//	until COND; do BODY; done
+//
+// The while loop runs its body as long as the condition succeeds and
+// the until loop as long as the condition fails. The exit status of
+// the loop is the exit status of the last body command or 0 if the
+// body was not run.
+type loopCommand struct {
+	until bool
+	cond  []node
+	body  []node
+}
+
+func (c *loopCommand) eval(p *Process) (int, error) {
+	loop.depth++
+	defer func() {
+		loop.depth--
+	}()
+
+	var status int
+	for !p.stopped() {
+		s, err := evalNodes(p, c.cond)
+		if err != nil {
+			return s, err
+		}
+		if !loopNext() {
+			break
+		}
+		if (s == 0) == c.until {
+			break
+		}
+		status, err = evalNodes(p, c.body)
+		if err != nil {
+			return status, err
+		}
+		if !loopNext() {
+			break
+		}
+	}
+	return status, nil
+}
+
+// loopNext consumes one loop level of a pending break or continue. It
+// returns false if the current loop must exit.
+func loopNext() bool {
+	if loop.breaks == 0 {
+		return true
+	}
+	loop.breaks--
+	if loop.breaks > 0 || !loop.cont {
+		return false
+	}
+	loop.cont = false
+	return true
+}
+
+// evalNodes evaluates the commands and returns the exit status of the
+// last command.
+func evalNodes(p *Process, nodes []node) (int, error) {
+	var status int
+	for _, n := range nodes {
+		if p.stopped() {
+			break
+		}
+		var err error
+		status, err = n.eval(p)
+		p.Status = status
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// parseCommands parses the commands of the current line of the
+// reader. The compound commands read their continuation lines from
+// the reader.
+func parseCommands(r *cmdReader) ([]node, error) {
+	var nodes []node
+	for {
+		cmd, err := r.command(false)
+		if err != nil {
+			return nil, err
+		}
+		if cmd == nil {
+			return nodes, nil
+		}
+		n, err := parseCommand(r, cmd)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// parseCommand parses the command that starts with the AND-OR list
+// cmd.
+func parseCommand(r *cmdReader, cmd *simpleCommand) (node, error) {
+	word, _ := cmd.keyword()
+	switch {
+	case word == "if":
+		if err := r.unreadRest(cmd); err != nil {
+			return nil, err
+		}
+		return parseIf(r)
+
+	case word == "while", word == "until":
+		if err := r.unreadRest(cmd); err != nil {
+			return nil, err
+		}
+		return parseLoop(r, word == "until")
+
+	case isReserved(word):
+		return nil, fmt.Errorf("syntax error near unexpected token `%s'",
+			word)
+	}
+	return cmd, nil
+}
+
+// parseIf parses the if command after its if keyword.
+func parseIf(r *cmdReader) (node, error) {
+	result := new(ifCommand)
+	word := "if"
+	for word != "fi" {
+		clause := new(ifClause)
+		var err error
+		if word != "else" {
+			clause.cond, _, err = parseList(r, word, "then")
+			if err != nil {
+				return nil, err
+			}
+			word = "then"
+		}
+		terms := []string{"elif", "else", "fi"}
+		if word == "else" {
+			terms = []string{"fi"}
+		}
+		clause.body, word, err = parseList(r, word, terms...)
+		if err != nil {
+			return nil, err
+		}
+		result.clauses = append(result.clauses, clause)
+	}
+	return result, nil
+}
+
+// parseLoop parses the while or until loop after its keyword.
+func parseLoop(r *cmdReader, until bool) (node, error) {
+	start := "while"
+	if until {
+		start = "until"
+	}
+	cond, _, err := parseList(r, start, "do")
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := parseList(r, "do", "done")
+	if err != nil {
+		return nil, err
+	}
+	return &loopCommand{
+		until: until,
+		cond:  cond,
+		body:  body,
+	}, nil
+}
+
+// parseList parses the non-empty command list following the keyword
+// up to one of the terminating keywords. It returns the commands and
+// the terminating keyword. The commands following the then, else,
+// and do keywords on the same line are returned to the reader.
+func parseList(r *cmdReader, keyword string, terms ...string) (
+	[]node, string, error) {
+
+	var nodes []node
+	for {
+		cmd, err := r.command(true)
+		if err != nil {
+			return nil, "", err
+		}
+		word, _ := cmd.keyword()
+		for _, term := range terms {
+			if word != term {
+				continue
+			}
+			if len(nodes) == 0 {
+				return nil, "", fmt.Errorf("syntax error near `%s'", word)
+			}
+			if word == "fi" || word == "done" {
+				err = cmd.terminator()
+			} else {
+				err = r.unreadRest(cmd)
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			return nodes, word, nil
+		}
+		n, err := parseCommand(r, cmd)
+		if err != nil {
+			return nil, "", err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// loopControl sets the loop state for the break and continue
+// commands. The loop count defaults to 1 and it is limited to the
+// number of enclosing loops.
+func loopControl(p *Process, args []string, cont bool) int {
+	n := 1
+	if len(args) > 2 {
+		fmt.Fprintf(p.Stderr, "usage: %s [n]\n", args[0])
+		return 2
+	}
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(p.Stderr, "%s: %s: numeric argument required\n",
+				args[0], args[1])
+			return 2
+		}
+		if n < 1 {
+			fmt.Fprintf(p.Stderr, "%s: %s: loop count out of range\n",
+				args[0], args[1])
+			return 1
+		}
+	}
+	if loop.depth == 0 {
+		fmt.Fprintf(p.Stderr, "%s: only meaningful in a loop\n", args[0])
+		return 0
+	}
+	if n > loop.depth {
+		n = loop.depth
+	}
+	loop.breaks = n
+	loop.cont = cont
+	return 0
+}
+
+// cmd_break exits from the n enclosing loops.
+func cmd_break(p *Process, args []string) int {
+	return loopControl(p, args, false)
+}
+
+// cmd_continue exits from the n-1 enclosing loops and continues the
+// next iteration of the nth loop.
+func cmd_continue(p *Process, args []string) int {
+	return loopControl(p, args, true)
+}
+
+// evalLines evaluates the lines and returns the exit status of the
+// last command. The multiline constructs read their continuation
+// lines from the remaining lines.
+func evalLines(p *Process, lines []string) (int, error) {
+	next := lineReader(lines)
+
+	var status int
+	for !p.stopped() {
+		line, err := next("")
+		if err != nil {
+			break
+		}
+		status, err = eval(p, line, next)
+		p.Status = status
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// lineReader returns a function that returns the lines one by one and
+// io.EOF after the last line.
+func lineReader(lines []string) func(prompt string) (string, error) {
+	return func(prompt string) (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+}
@@ -0,0 +1,358 @@
+//
+// compound_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var compoundTests = []struct {
+	script string
+	stdout string
+	status int
+	err    bool
+}{
+	{
+		script: "! true",
+		status: 1,
+	},
+	{
+		script: "! false",
+		status: 0,
+	},
+	{
+		script: "! test -f /no/such/file",
+		status: 0,
+	},
+	{
+		script: "!",
+		status: 1,
+	},
+	{
+		script: `if ! test -f /no/such/file; then
+cat <<EOF
+missing
+EOF
+else
+cat <<EOF
+found
+EOF
+fi
+`,
+		stdout: "missing\n",
+	},
+	{
+		script: `if ! true
+then
+cat <<EOF
+negated
+EOF
+fi
+`,
+	},
+	{
+		script: `if false; then
+cat <<EOF
+first
+EOF
+elif true; then
+if false; then
+cat <<EOF
+nested
+EOF
+else
+cat <<EOF
+second
+EOF
+fi
+fi
+`,
+		stdout: "second\n",
+	},
+	{
+		script: `if false; then
+true
+else
+false
+fi
+`,
+		status: 1,
+	},
+	{
+		script: "if true; then yes y | head -n 1; fi",
+		stdout: "y\n",
+	},
+	{
+		script: "if false; then yes a | head -n 1; elif true; then " +
+			"yes b | head -n 1; else yes c | head -n 1; fi",
+		stdout: "b\n",
+	},
+	{
+		script: "if false; then yes a | head -n 1; elif false; then " +
+			"yes b | head -n 1; else yes c | head -n 1; fi",
+		stdout: "c\n",
+	},
+	{
+		script: "if false; then true; else false; fi; yes d | head -n 1",
+		stdout: "d\n",
+	},
+	{
+		script: "if true; then if false; then false; fi; fi",
+	},
+	{
+		script: "if\ttrue;\tthen\tyes t | head -n 1;\tfi",
+		stdout: "t\n",
+	},
+	{
+		script: "if true\nthen\tyes t | head -n 1\nelse\tfalse\nfi\n",
+		stdout: "t\n",
+	},
+	{
+		script: "if true; then; fi",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "if true; then true; fi &",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "then true",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `if true; then
+true
+`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `if true
+false
+fi
+`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `if false; then
+true
+else
+true
+else
+true
+fi
+`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `n=3
+while test $n -gt 0; do
+test $n -eq 1 && n=0
+test $n -eq 2 && n=1
+test $n -eq 3 && n=2
+yes $n | head -n 1
+done
+`,
+		stdout: "2\n1\n0\n",
+	},
+	{
+		script: `n=0
+until test $n -eq 3
+do
+test $n -eq 2 && n=3
+test $n -eq 1 && n=2
+test $n -eq 0 && n=1
+yes $n | head -n 1
+done
+`,
+		stdout: "1\n2\n3\n",
+	},
+	{
+		script: `false
+while false; do
+true
+done
+`,
+	},
+	{
+		script: `until true; do
+false
+done
+`,
+	},
+	{
+		script: `while true; do
+yes a | head -n 1
+if true; then
+break
+fi
+yes b | head -n 1
+done
+`,
+		stdout: "a\n",
+	},
+	{
+		script: `n=0
+while test $n -lt 2; do
+test $n -eq 1 && n=2
+test $n -eq 0 && n=1
+test $n -eq 1 && continue
+yes $n | head -n 1
+done
+`,
+		stdout: "2\n",
+	},
+	{
+		script: `while true; do
+while true; do
+yes inner | head -n 1
+break 2
+done
+yes outer | head -n 1
+done
+yes after | head -n 1
+`,
+		stdout: "inner\nafter\n",
+	},
+	{
+		script: `n=0
+while test $n -lt 2; do
+test $n -eq 1 && n=2
+test $n -eq 0 && n=1
+until false; do
+yes $n | head -n 1
+continue 2
+done
+yes skipped | head -n 1
+done
+`,
+		stdout: "1\n2\n",
+	},
+	{
+		script: `while true; do
+break 5
+done
+yes after | head -n 1
+`,
+		stdout: "after\n",
+	},
+	{
+		script: "while false; do true; done",
+	},
+	{
+		script: "until true; do false; done",
+	},
+	{
+		script: "n=0; until test $n -eq 2; do n=2; yes $n | head -n 1; done",
+		stdout: "2\n",
+	},
+	{
+		script: "while true; do yes a | head -n 1; break; done; yes b | head -n 1",
+		stdout: "a\nb\n",
+	},
+	{
+		script: `while true; do cat <<EOF; break; done
+a
+EOF
+`,
+		stdout: "a\n",
+	},
+	{
+		script: `while true; do
+yes a | head -n 1; break; done; yes after | head -n 1
+`,
+		stdout: "a\nafter\n",
+	},
+	{
+		script: "while true; do while true; do break 2; done; done",
+	},
+	{
+		script: "while false; do true; done &",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "while false; do; done",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "while false; do true; done yes",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "done",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "break",
+	},
+	{
+		script: "continue x",
+		status: 2,
+	},
+	{
+		script: `while true; do
+break 0
+break
+done
+`,
+	},
+	{
+		script: `while true; do
+true
+`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `while true
+true
+done
+`,
+		status: 1,
+		err:    true,
+	},
+	{
+		script: `until
+done
+`,
+		status: 1,
+		err:    true,
+	},
+}
+
+func TestCompound(t *testing.T) {
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range compoundTests {
+		stdout, _, status, err := EvalCapture(p, test.script)
+		if err != nil != test.err {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout: got %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if status != test.status {
+			t.Errorf("%q: status: got %d, expected %d",
+				test.script, status, test.status)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+//
+// env.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "set",
+			Usage: "set [name=value...]",
+			Help:  "Set or list the shell variables.",
+			Cmd:   cmd_set,
+		},
+		Builtin{
+			Name:  "export",
+			Usage: "export [name[=value]...]",
+			Help:  "Set or list the exported variables.",
+			Cmd:   cmd_export,
+		},
+	}...)
+}
+
+// newEnv creates the shell variables from the process environment.
+func newEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, ok := assignment(kv)
+		if ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// cloneEnv returns a copy of the shell variables.
+func cloneEnv(env map[string]string) map[string]string {
+	result := make(map[string]string, len(env))
+	for k, v := range env {
+		result[k] = v
+	}
+	return result
+}
+
+// Getenv returns the value of the shell variable. The undefined
+// variables have empty values.
+func (p *Process) Getenv(name string) string {
+	return p.Env[name]
+}
+
+// Setenv sets the value of the shell variable.
+func (p *Process) Setenv(name, value string) {
+	if p.Env == nil {
+		p.Env = make(map[string]string)
+	}
+	p.Env[name] = value
+}
+
+// lookup returns the value of the parameter or variable name. The
+// positional parameters are joined with spaces.
+func (p *Process) lookup(name string) string {
+	switch name {
+	case "?":
+		return strconv.Itoa(p.Status)
+
+	case "$":
+		return strconv.Itoa(shellPID)
+
+	case "!":
+		if lastJob == 0 {
+			return ""
+		}
+		return strconv.Itoa(lastJob)
+
+	case "#":
+		if len(p.Args) == 0 {
+			return "0"
+		}
+		return strconv.Itoa(len(p.Args) - 1)
+
+	case "@", "*":
+		if len(p.Args) < 2 {
+			return ""
+		}
+		return strings.Join(p.Args[1:], " ")
+	}
+	idx, err := strconv.Atoi(name)
+	if err == nil {
+		if idx < len(p.Args) {
+			return p.Args[idx]
+		}
+		return ""
+	}
+	return p.Getenv(name)
+}
+
+// isName tests if the argument is a valid variable name.
+func isName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// assignment parses the name=value variable assignment.
+func assignment(arg string) (name, value string, ok bool) {
+	idx := strings.IndexByte(arg, '=')
+	if idx <= 0 || !isName(arg[:idx]) {
+		return "", "", false
+	}
+	return arg[:idx], arg[idx+1:], true
+}
+
+// parseAssignments extracts the leading variable assignments from
+// the command arguments.
+func parseAssignments(args CommandLine) (CommandLine, []string) {
+	for idx, arg := range args {
+		if _, _, ok := assignment(arg); !ok {
+			return args[idx:], args[:idx]
+		}
+	}
+	return nil, args
+}
+
+// assign sets the variable assignments. If the command is set, the
+// assignments are made to a copy of the shell variables so that they
+// only apply to the command.
+func assign(p *Process, assigns []string, command bool) {
+	if len(assigns) == 0 {
+		return
+	}
+	if command {
+		p.Env = cloneEnv(p.Env)
+	}
+	for _, a := range assigns {
+		name, value, _ := assignment(a)
+		p.Setenv(name, value)
+	}
+}
+
+func printVars(p *Process, prefix string, env map[string]string) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(p.Stdout, "%s%s=%s\n", prefix, name, env[name])
+	}
+}
+
+// cmd_set sets the shell variables. Without arguments, it lists all
+// shell variables.
+func cmd_set(p *Process, args []string) int {
+	if len(args) < 2 {
+		printVars(p, "", p.Env)
+		return 0
+	}
+	for _, arg := range args[1:] {
+		if _, _, ok := assignment(arg); !ok {
+			fmt.Fprintf(p.Stderr, "set: invalid assignment: %s\n",
+				arg)
+			return 2
+		}
+	}
+	assign(p, args[1:], false)
+	return 0
+}
+
+// cmd_export sets the shell variables and exports them to the process
+// environment. Without arguments, it lists the exported variables.
+func cmd_export(p *Process, args []string) int {
+	if len(args) < 2 {
+		exported := make(map[string]string)
+		for _, kv := range os.Environ() {
+			name, value, ok := assignment(kv)
+			if ok {
+				exported[name] = value
+			}
+		}
+		printVars(p, "export ", exported)
+		return 0
+	}
+	var status int
+	for _, arg := range args[1:] {
+		name, value, ok := assignment(arg)
+		if ok {
+			p.Setenv(name, value)
+		} else if isName(arg) {
+			name = arg
+			value = p.Getenv(name)
+		} else {
+			fmt.Fprintf(p.Stderr, "export: invalid name: %s\n", arg)
+			status = 1
+			continue
+		}
+		os.Setenv(name, value)
+	}
+	return status
+}
@@ -0,0 +1,95 @@
+//
+// env_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	defer os.Unsetenv("TEST_EXPORT")
+
+	tests := []struct {
+		script string
+		stdout string
+		stderr string
+		status int
+	}{
+		{
+			script: "A=x B=y\nset",
+			stdout: "A=x\nB=y\n",
+		},
+		{
+			script: "set A=x B=$A\nset",
+			stdout: "A=x\nB=\n",
+		},
+		{
+			script: "A=x\nset B=$A C=${A}y \"D=$A z\"\nset",
+			stdout: "A=x\nB=x\nC=xy\nD=x z\n",
+		},
+		{
+			script: "A=1\nA=2 set\nset",
+			stdout: "A=2\nA=1\n",
+		},
+		{
+			script: "A=1 set | cat\nset",
+			stdout: "A=1\n",
+		},
+		{
+			script: "A=x\ncat <<EOF\n$A ${A}y $B.\nEOF",
+			stdout: "x xy .\n",
+		},
+		{
+			script: "false\nset S=$?\ntrue\nset T=$?\nset",
+			stdout: "S=1\nT=0\n",
+		},
+		{
+			script: "set 1A=x",
+			stderr: "set: invalid assignment: 1A=x\n",
+			status: 2,
+		},
+		{
+			script: "export TEST_EXPORT=x\nset",
+			stdout: "TEST_EXPORT=x\n",
+		},
+		{
+			script: "export 1A",
+			stderr: "export: invalid name: 1A\n",
+			status: 1,
+		},
+	}
+	for _, test := range tests {
+		p := &Process{
+			Stdin: strings.NewReader(""),
+			Env:   make(map[string]string),
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+	if v := os.Getenv("TEST_EXPORT"); v != "x" {
+		t.Errorf("export: got %q, expected %q", v, "x")
+	}
+}
@@ -0,0 +1,84 @@
+//
+// heredoc.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Heredoc implements the here-document redirection (<<DELIM and
+// <<-DELIM).
+type Heredoc struct {
+	Delim     string
+	StripTabs bool
+	Expand    bool
+	body      string
+}
+
+// parseHeredoc extracts the here-document redirection from the
+// command arguments. It returns the remaining arguments and the
+// here-document, or nil if the command does not have one. Quoting
+// any part of the delimiter disables the expansion of the
+// here-document body.
+func parseHeredoc(args []Token) ([]Token, *Heredoc, error) {
+	for idx, arg := range args {
+		if !arg.Op || !strings.HasPrefix(arg.Text, "<<") {
+			continue
+		}
+		next := idx + 1
+		if next >= len(args) || args[next].Op ||
+			len(args[next].Text) == 0 {
+			return nil, nil, fmt.Errorf("syntax error: missing delimiter")
+		}
+		hd := &Heredoc{
+			Delim:     args[next].Text,
+			StripTabs: arg.Text == "<<-",
+			Expand:    !args[next].Quoted,
+		}
+
+		var result []Token
+		result = append(result, args[:idx]...)
+		result = append(result, args[next+1:]...)
+
+		return result, hd, nil
+	}
+	return args, nil, nil
+}
+
+// read reads the here-document body up to the delimiter line. The
+// mapping function expands the variables of the body lines.
+func (hd *Heredoc) read(next func(prompt string) (string, error),
+	mapping func(name string) string) error {
+	var sb strings.Builder
+
+	for {
+		line, err := next("> ")
+		if err != nil {
+			return fmt.Errorf(
+				"here-document delimited by end-of-file (wanted `%s')",
+				hd.Delim)
+		}
+		if hd.StripTabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == hd.Delim {
+			break
+		}
+		if hd.Expand {
+			line = os.Expand(line, mapping)
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	hd.body = sb.String()
+
+	return nil
+}
@@ -0,0 +1,97 @@
+//
+// heredoc_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var heredocTests = []struct {
+	line   string
+	input  []string
+	output string
+}{
+	{
+		line:   "cat <<EOF",
+		input:  []string{"Hello,", "  world!", "EOF"},
+		output: "Hello,\n  world!\n",
+	},
+	{
+		line:   "cat << END",
+		input:  []string{"a", "END"},
+		output: "a\n",
+	},
+	{
+		line:   "cat <<-EOF",
+		input:  []string{"\t\tindented", "\tline", "\tEOF"},
+		output: "indented\nline\n",
+	},
+	{
+		line:   "cat <<EOF",
+		input:  []string{"Hello, $NAME!", "EOF"},
+		output: "Hello, world!\n",
+	},
+	{
+		line:   "cat <<'EOF'",
+		input:  []string{"Hello, $NAME!", "EOF"},
+		output: "Hello, $NAME!\n",
+	},
+	{
+		line:   "cat <<\"EOF\"",
+		input:  []string{"Hello, ${NAME}!", "EOF"},
+		output: "Hello, ${NAME}!\n",
+	},
+	{
+		line:   "cat <<E\\OF",
+		input:  []string{"Hello, $NAME!", "EOF"},
+		output: "Hello, $NAME!\n",
+	},
+	{
+		line:   "cat <<$NAME",
+		input:  []string{"Hello, $NAME!", "$NAME"},
+		output: "Hello, world!\n",
+	},
+}
+
+func TestHeredoc(t *testing.T) {
+	for _, test := range heredocTests {
+		stdout := new(bytes.Buffer)
+		p := &Process{
+			Stdin:  strings.NewReader(""),
+			Stdout: stdout,
+			Stderr: stdout,
+			Env: map[string]string{
+				"NAME": "world",
+			},
+		}
+		_, err := eval(p, test.line, lineReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		if stdout.String() != test.output {
+			t.Errorf("%s: got %q, expected %q",
+				test.line, stdout.String(), test.output)
+		}
+	}
+}
+
+func TestHeredocEOF(t *testing.T) {
+	p := &Process{
+		Stdin:  strings.NewReader(""),
+		Stdout: new(bytes.Buffer),
+		Stderr: new(bytes.Buffer),
+	}
+	_, err := eval(p, "cat <<EOF", lineReader([]string{"no delimiter"}))
+	if err == nil {
+		t.Errorf("unterminated here-document did not fail")
+	}
+}
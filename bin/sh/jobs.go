@@ -0,0 +1,266 @@
+//
+// jobs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "jobs",
+			Usage: "jobs [-l]",
+			Help:  "List the background jobs.",
+			Cmd:   cmd_jobs,
+		},
+		Builtin{
+			Name:  "fg",
+			Usage: "fg [%n]",
+			Help:  "Wait for the background job n to complete.",
+			Cmd:   cmd_fg,
+		},
+	}...)
+}
+
+// Job implements a background job. The interrupt delivers signals to
+// the job's commands.
+type Job struct {
+	ID        int
+	PID       int
+	Line      string
+	status    int
+	done      chan struct{}
+	interrupt *Interrupt
+}
+
+// Done tests if the job has terminated.
+func (job *Job) Done() bool {
+	select {
+	case <-job.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait waits for the job to terminate and returns its exit status.
+func (job *Job) Wait() int {
+	<-job.done
+	return job.status
+}
+
+func (job *Job) String() string {
+	if !job.Done() {
+		return "Running"
+	}
+	if job.status == 0 {
+		return "Done"
+	}
+	return fmt.Sprintf("Exit %d", job.status)
+}
+
+var (
+	// jobs contains the background jobs in the order they were
+	// started.
+	jobs []*Job
+
+	// jobPID is the process ID of the latest job. The jobs run
+	// inside the shell so the shell allocates their process IDs
+	// after its own.
+	jobPID = shellPID
+
+	// lastJob is the process ID of the latest background job,
+	// expanded by $!. It is zero before the first job.
+	lastJob int
+)
+
+// startJob starts the AND-OR list in the background. The first
+// pipeline is parsed before the job starts and the rest of the list
+// is evaluated in the job. The heredocs hold the here-document lines
+// of the pipelines. The job reads its standard input from an empty
+// reader.
+func startJob(p *Process, list andOrList, heredocs [][]string) (
+	int, error) {
+
+	args, err := tokenize(list.pipelines[0].line, p)
+	if err != nil {
+		return 1, err
+	}
+	var negate bool
+	if len(args) > 0 && args[0].Text == "!" {
+		negate = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 1, fmt.Errorf("syntax error near unexpected token `&'")
+	}
+	if isCompound(args[0].Text) {
+		return 1, fmt.Errorf("background compound commands not supported")
+	}
+	stages, err := parsePipeline(p, args, lineReader(heredocs[0]))
+	if err != nil {
+		return 1, err
+	}
+
+	id := 1
+	if len(jobs) > 0 {
+		id = jobs[len(jobs)-1].ID + 1
+	}
+	jobPID++
+	lastJob = jobPID
+	job := &Job{
+		ID:        id,
+		PID:       jobPID,
+		Line:      list.String(),
+		done:      make(chan struct{}),
+		interrupt: NewInterrupt(),
+	}
+	jobs = append(jobs, job)
+
+	jp := &Process{
+		Stdin:      strings.NewReader(""),
+		Stdout:     p.Stdout,
+		Stderr:     p.Stderr,
+		WorkingDir: p.WorkingDir,
+		Args:       p.Args,
+		Env:        cloneEnv(p.Env),
+		Status:     p.Status,
+		Interrupt:  job.interrupt,
+	}
+	go func() {
+		status := runPipeline(jp, stages)
+		if negate {
+			if status == 0 {
+				status = 1
+			} else {
+				status = 0
+			}
+		}
+		jp.Status = status
+		status, err := evalAndOr(jp, status, list.pipelines[1:],
+			heredocs[1:])
+		if err != nil {
+			fmt.Fprintf(jp.Stderr, "%s\n", err)
+		}
+		job.status = status
+		close(job.done)
+	}()
+
+	fmt.Fprintf(p.Stderr, "[%d]\n", job.ID)
+	return 0, nil
+}
+
+// printJob prints the job status line. The current job, that is, the
+// latest job, is marked with + and the previous job with -. The long
+// format includes the job's process ID.
+func printJob(w io.Writer, idx int, job *Job, long bool) {
+	mark := ' '
+	switch idx {
+	case len(jobs) - 1:
+		mark = '+'
+	case len(jobs) - 2:
+		mark = '-'
+	}
+	if long {
+		fmt.Fprintf(w, "[%d]%c %d %-24s%s\n", job.ID, mark, job.PID, job,
+			job.Line)
+	} else {
+		fmt.Fprintf(w, "[%d]%c  %-24s%s\n", job.ID, mark, job, job.Line)
+	}
+}
+
+// reportJobs prints the terminated jobs and removes them from the job
+// table.
+func reportJobs(w io.Writer) {
+	var running []*Job
+	for idx, job := range jobs {
+		if job.Done() {
+			printJob(w, idx, job, false)
+		} else {
+			running = append(running, job)
+		}
+	}
+	jobs = running
+}
+
+func cmd_jobs(p *Process, args []string) int {
+	long := p.Flags.Bool("l", false, "List the process IDs of the jobs.")
+	if err := p.Flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	for idx, job := range jobs {
+		printJob(p.Stdout, idx, job, *long)
+	}
+	var running []*Job
+	for _, job := range jobs {
+		if !job.Done() {
+			running = append(running, job)
+		}
+	}
+	jobs = running
+	return 0
+}
+
+// findJob returns the index of the job specified as %n or n, or -1
+// if the job is not found.
+func findJob(arg string) int {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "%"))
+	if err != nil {
+		return -1
+	}
+	for idx, job := range jobs {
+		if job.ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// findJobPID returns the index of the job with the process ID, or -1
+// if the job is not found.
+func findJobPID(pid int) int {
+	for idx, job := range jobs {
+		if job.PID == pid {
+			return idx
+		}
+	}
+	return -1
+}
+
+// cmd_fg waits for the job and returns its exit status. The job is
+// specified as %n or n and it defaults to the current job. The
+// signals delivered to the fg command are passed to the job.
+func cmd_fg(p *Process, args []string) int {
+	if len(jobs) == 0 {
+		fmt.Fprintf(p.Stderr, "fg: no current job\n")
+		return 1
+	}
+	idx := len(jobs) - 1
+	if len(args) > 1 {
+		idx = findJob(args[1])
+		if idx < 0 {
+			fmt.Fprintf(p.Stderr, "fg: %s: no such job\n", args[1])
+			return 1
+		}
+	}
+	job := jobs[idx]
+	jobs = append(jobs[:idx:idx], jobs[idx+1:]...)
+
+	fmt.Fprintf(p.Stdout, "%s\n", job.Line)
+	select {
+	case <-job.done:
+	case <-p.Interrupt.Done():
+		job.interrupt.Signal(p.Interrupt.Signalled())
+	}
+	return job.Wait()
+}
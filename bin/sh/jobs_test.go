@@ -0,0 +1,194 @@
+//
+// jobs_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jobTests = []struct {
+	script string
+	stdout string
+	stderr string
+	status int
+}{
+	{
+		script: "sleep 0.2 &\njobs\nfg %1\njobs",
+		stdout: "[1]+  Running                 sleep 0.2\nsleep 0.2\n",
+		stderr: "[1]\n",
+	},
+	{
+		script: "false &\ntrue &\nsleep 0.05\njobs\njobs",
+		stdout: "[1]-  Exit 1                  false\n" +
+			"[2]+  Done                    true\n",
+		stderr: "[1]\n[2]\n",
+	},
+	{
+		script: "! false &\nfg",
+		stdout: "! false\n",
+		stderr: "[1]\n",
+	},
+	{
+		script: "sleep 1 &\nfg %2",
+		stderr: "[1]\nfg: %2: no such job\n",
+		status: 1,
+	},
+	{
+		script: "fg",
+		stderr: "fg: no current job\n",
+		status: 1,
+	},
+	{
+		script: "sleep 0.2 & jobs; fg",
+		stdout: "[1]+  Running                 sleep 0.2\nsleep 0.2\n",
+		stderr: "[1]\n",
+	},
+	{
+		script: "true & false & sleep 0.05; jobs",
+		stdout: "[1]-  Done                    true\n" +
+			"[2]+  Exit 1                  false\n",
+		stderr: "[1]\n[2]\n",
+	},
+	{
+		script: "true && false &\nsleep 0.05; jobs",
+		stdout: "[1]+  Exit 1                  true && false\n",
+		stderr: "[1]\n",
+	},
+	{
+		script: "sleep 0.05 && false || yes a | head -n 1 && ! true &\nfg",
+		stdout: "sleep 0.05 && false || yes a | head -n 1 && ! true\na\n",
+		stderr: "[1]\n",
+		status: 1,
+	},
+	{
+		script: "sleep 0.05 && x=1 && yes $x | head -n 1 &\nx=2; fg",
+		stdout: "sleep 0.05 && x=1 && yes $x | head -n 1\n1\n",
+		stderr: "[1]\n",
+	},
+}
+
+func TestJobs(t *testing.T) {
+	defer func() {
+		jobs = nil
+	}()
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range jobTests {
+		jobs = nil
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
+
+func TestJobPID(t *testing.T) {
+	defer func() {
+		jobs = nil
+	}()
+	jobs = nil
+	jobPID = 100
+	lastJob = 0
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	stdout, _, _, err := EvalCapture(p, `yes "[$!]" | head -n 1
+sleep 0.2 &
+yes "[$!]" | head -n 1
+jobs -l
+sleep 0.2 &
+yes $! | head -n 1
+fg; fg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[]\n[101]\n" +
+		"[1]+ 101 Running                 sleep 0.2\n" +
+		"102\nsleep 0.2\nsleep 0.2\n"
+	if stdout != expected {
+		t.Errorf("stdout %q, expected %q", stdout, expected)
+	}
+}
+
+func TestFgInterrupt(t *testing.T) {
+	defer func() {
+		jobs = nil
+	}()
+	jobs = nil
+	p := &Process{
+		Stdin:     strings.NewReader(""),
+		Interrupt: NewInterrupt(),
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.Interrupt.Signal(SIGINT)
+	}()
+	start := time.Now()
+	stdout, _, status, err := EvalCapture(p, "sleep 10 &\nfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fg took %v", elapsed)
+	}
+	if stdout != "sleep 10\n" {
+		t.Errorf("stdout %q, expected %q", stdout, "sleep 10\n")
+	}
+	if status != SIGINT.ExitStatus() {
+		t.Errorf("status %v, expected %v", status, SIGINT.ExitStatus())
+	}
+}
+
+func TestReportJobs(t *testing.T) {
+	defer func() {
+		// Terminate the running jobs so that they do not leak into
+		// the other tests.
+		for _, job := range jobs {
+			job.interrupt.Signal(SIGKILL)
+			job.Wait()
+		}
+		jobs = nil
+	}()
+	jobs = nil
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	_, _, _, err := EvalCapture(p,
+		"true &\nfalse &\nsleep 10 &\nsleep 0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	reportJobs(&buf)
+	expected := "[1]   Done                    true\n" +
+		"[2]-  Exit 1                  false\n"
+	if buf.String() != expected {
+		t.Errorf("reportJobs: got %q, expected %q", buf.String(), expected)
+	}
+	if len(jobs) != 1 || jobs[0].Line != "sleep 10" {
+		t.Errorf("reportJobs: running jobs %v", jobs)
+	}
+}
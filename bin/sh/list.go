@@ -0,0 +1,288 @@
+//
+// list.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// andOr defines a pipeline of an AND-OR list. The op is the && or ||
+// operator preceding the pipeline. It is empty for the first
+// pipeline of the list.
+type andOr struct {
+	op   string
+	line string
+}
+
+// andOrList defines an AND-OR list of pipelines. The background list
+// runs as a job.
+type andOrList struct {
+	pipelines  []andOr
+	background bool
+}
+
+func (l andOrList) String() string {
+	var sb strings.Builder
+	for idx, pl := range l.pipelines {
+		if idx > 0 {
+			sb.WriteString(" " + pl.op + " ")
+		}
+		sb.WriteString(pl.line)
+	}
+	return sb.String()
+}
+
+// splitList splits the command line into lists separated by unquoted
+// ; and & and the lists into AND-OR lists of pipelines separated by
+// unquoted && and ||. The list terminated by & runs in the
+// background. The ; and & have the lowest precedence and the && and
+// || have equal precedence and they associate from left to right.
+// The pipelines are returned unexpanded so that they are expanded
+// just before they are evaluated.
+func splitList(line string) ([]andOrList, error) {
+	var lists []andOrList
+	var list []andOr
+	var op string
+	var start int
+
+	pipeline := func(end int, token string) error {
+		text := strings.TrimSpace(line[start:end])
+		if len(text) == 0 {
+			if len(token) == 0 {
+				return fmt.Errorf("syntax error: unexpected end of line")
+			}
+			return fmt.Errorf("syntax error near `%s'", token)
+		}
+		list = append(list, andOr{
+			op:   op,
+			line: text,
+		})
+		return nil
+	}
+	endList := func(end int, token string) error {
+		if len(list) == 0 && len(strings.TrimSpace(line[start:end])) == 0 {
+			if len(token) > 0 {
+				return fmt.Errorf("syntax error near `%s'", token)
+			}
+			return nil
+		}
+		if err := pipeline(end, token); err != nil {
+			return err
+		}
+		lists = append(lists, andOrList{
+			pipelines:  list,
+			background: token == "&",
+		})
+		list = nil
+		op = ""
+		return nil
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '\\':
+			i++
+
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				// The tokenizer reports the unterminated quote.
+				i = len(line)
+			} else {
+				i += end + 1
+			}
+
+		case '"':
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+
+		case ';':
+			if err := endList(i, ";"); err != nil {
+				return nil, err
+			}
+			start = i + 1
+
+		case '&', '|':
+			if i+1 >= len(line) || line[i+1] != c {
+				// The | is a pipeline and the & of >& and <& starts
+				// a file descriptor duplication target, such as
+				// >&2 and <&0.
+				if c == '|' || i > 0 && (line[i-1] == '>' ||
+					line[i-1] == '<') {
+					break
+				}
+				if err := endList(i, "&"); err != nil {
+					return nil, err
+				}
+				start = i + 1
+				break
+			}
+			token := line[i : i+2]
+			if err := pipeline(i, token); err != nil {
+				return nil, err
+			}
+			op = token
+			i++
+			start = i + 1
+		}
+	}
+	if err := endList(len(line), ""); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// evalAndOr evaluates the AND-OR list from left to right. The
+// pipeline following && runs if the previous pipeline succeeded and
+// the pipeline following || runs if it failed. The function returns
+// the exit status of the last pipeline that was run. The status is
+// the exit status preceding the list and the list's first pipeline
+// is tested against it if it has an operator. The heredocs hold the
+// here-document lines of the pipelines.
+func evalAndOr(p *Process, status int, list []andOr,
+	heredocs [][]string) (int, error) {
+
+	for idx, pl := range list {
+		if p.stopped() {
+			break
+		}
+		if pl.op == "&&" && status != 0 || pl.op == "||" && status == 0 {
+			continue
+		}
+		var err error
+		status, err = evalPipeline(p, pl.line, lineReader(heredocs[idx]))
+		if err != nil {
+			return status, err
+		}
+		p.Status = status
+	}
+	return status, nil
+}
+
+// cmdReader reads commands from command lines. It splits the lines
+// into AND-OR lists and reads the here-document lines of their
+// pipelines so that the commands can be parsed before they are
+// evaluated.
+type cmdReader struct {
+	pending []*simpleCommand
+	next    func(prompt string) (string, error)
+}
+
+// split splits the line into commands and appends them to the
+// pending commands.
+func (r *cmdReader) split(line string) error {
+	lists, err := splitList(line)
+	if err != nil {
+		return err
+	}
+	for _, list := range lists {
+		cmd := &simpleCommand{
+			list: list,
+		}
+		for _, pl := range list.pipelines {
+			heredoc, err := readHeredocs(pl.line, r.next)
+			if err != nil {
+				return err
+			}
+			cmd.heredocs = append(cmd.heredocs, heredoc)
+		}
+		r.pending = append(r.pending, cmd)
+	}
+	return nil
+}
+
+// command returns the next pending command. If there are no pending
+// commands and more is set, the function reads the next line and
+// fails if the input ends. Otherwise it returns nil.
+func (r *cmdReader) command(more bool) (*simpleCommand, error) {
+	for len(r.pending) == 0 {
+		if !more {
+			return nil, nil
+		}
+		line, err := r.next("> ")
+		if err != nil {
+			return nil, fmt.Errorf("syntax error: unexpected end of file")
+		}
+		if err := r.split(line); err != nil {
+			return nil, err
+		}
+	}
+	cmd := r.pending[0]
+	r.pending = r.pending[1:]
+	return cmd, nil
+}
+
+// unreadRest returns the command following the command's leading
+// keyword to the pending commands.
+func (r *cmdReader) unreadRest(cmd *simpleCommand) error {
+	_, rest := cmd.keyword()
+	pipelines := cmd.list.pipelines
+	if len(rest) == 0 {
+		if len(pipelines) > 1 {
+			return fmt.Errorf("syntax error near `%s'", pipelines[1].op)
+		}
+		if cmd.list.background {
+			return fmt.Errorf("syntax error near `&'")
+		}
+		return nil
+	}
+	first := pipelines[0]
+	first.line = rest
+
+	r.pending = append([]*simpleCommand{
+		&simpleCommand{
+			list: andOrList{
+				pipelines:  append([]andOr{first}, pipelines[1:]...),
+				background: cmd.list.background,
+			},
+			heredocs: cmd.heredocs,
+		},
+	}, r.pending...)
+	return nil
+}
+
+// readHeredocs reads the lines of the here-documents of the pipeline
+// line. The lines of each here-document end with its delimiter line.
+func readHeredocs(line string, next func(prompt string) (string, error)) (
+	[]string, error) {
+
+	tokens, err := tokenize(line, nil)
+	if err != nil {
+		// The evaluation reports the syntax error.
+		return nil, nil
+	}
+	var result []string
+	for idx, token := range tokens {
+		if !token.Op || !strings.HasPrefix(token.Text, "<<") ||
+			idx+1 >= len(tokens) || tokens[idx+1].Op {
+			continue
+		}
+		delim := tokens[idx+1].Text
+		for {
+			l, err := next("> ")
+			if err != nil {
+				return nil, fmt.Errorf(
+					"here-document delimited by end-of-file (wanted `%s')",
+					delim)
+			}
+			result = append(result, l)
+			if token.Text == "<<-" {
+				l = strings.TrimLeft(l, "\t")
+			}
+			if l == delim {
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,187 @@
+//
+// list_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pipelines creates a foreground AND-OR list of the pipelines.
+func pipelines(pl ...andOr) andOrList {
+	return andOrList{
+		pipelines: pl,
+	}
+}
+
+var splitListTests = []struct {
+	line  string
+	lists []andOrList
+	err   string
+}{
+	{
+		line: "",
+	},
+	{
+		line:  "true",
+		lists: []andOrList{pipelines(andOr{"", "true"})},
+	},
+	{
+		line: "a; b;",
+		lists: []andOrList{
+			pipelines(andOr{"", "a"}),
+			pipelines(andOr{"", "b"}),
+		},
+	},
+	{
+		line: "a && b || c; d | e || f && g",
+		lists: []andOrList{
+			pipelines(andOr{"", "a"}, andOr{"&&", "b"}, andOr{"||", "c"}),
+			pipelines(andOr{"", "d | e"}, andOr{"||", "f"},
+				andOr{"&&", "g"}),
+		},
+	},
+	{
+		line: `a '&&' "||;" \; 2>&1 && b "c"`,
+		lists: []andOrList{
+			pipelines(andOr{"", `a '&&' "||;" \; 2>&1`},
+				andOr{"&&", `b "c"`}),
+		},
+	},
+	{
+		line: "a && b &",
+		lists: []andOrList{
+			{
+				pipelines:  []andOr{{"", "a"}, {"&&", "b"}},
+				background: true,
+			},
+		},
+	},
+	{
+		line: "sleep 1 & b \\& 'c &' >&2 <&0&",
+		lists: []andOrList{
+			{
+				pipelines:  []andOr{{"", "sleep 1"}},
+				background: true,
+			},
+			{
+				pipelines:  []andOr{{"", "b \\& 'c &' >&2 <&0"}},
+				background: true,
+			},
+		},
+	},
+	{
+		line: "a & b; c",
+		lists: []andOrList{
+			{
+				pipelines:  []andOr{{"", "a"}},
+				background: true,
+			},
+			pipelines(andOr{"", "b"}),
+			pipelines(andOr{"", "c"}),
+		},
+	},
+	{
+		line: "; a",
+		err:  "syntax error near `;'",
+	},
+	{
+		line: "a;;",
+		err:  "syntax error near `;'",
+	},
+	{
+		line: "a &;",
+		err:  "syntax error near `;'",
+	},
+	{
+		line: "& a",
+		err:  "syntax error near `&'",
+	},
+	{
+		line: "&& a",
+		err:  "syntax error near `&&'",
+	},
+	{
+		line: "a || && b",
+		err:  "syntax error near `&&'",
+	},
+	{
+		line: "a &&",
+		err:  "syntax error: unexpected end of line",
+	},
+	{
+		line: "a ||;",
+		err:  "syntax error near `;'",
+	},
+}
+
+func TestSplitList(t *testing.T) {
+	for _, test := range splitListTests {
+		lists, err := splitList(test.line)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: got error %v, expected %q",
+					test.line, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(lists, test.lists) {
+			t.Errorf("%q: got %v, expected %v", test.line, lists, test.lists)
+		}
+	}
+}
+
+var andOrTests = []struct {
+	script string
+	stdout string
+	status int
+}{
+	{"true && yes a | head -n 1", "a\n", 0},
+	{"false && yes a | head -n 1", "", 1},
+	{"false || yes b | head -n 1", "b\n", 0},
+	{"true || yes b | head -n 1", "", 0},
+	{"false && yes a | head -n 1 || yes b | head -n 1", "b\n", 0},
+	{"true || yes a | head -n 1 && yes b | head -n 1", "b\n", 0},
+	{"false || false && yes a | head -n 1", "", 1},
+	{"false; yes $? | head -n 1", "1\n", 0},
+	{"false || test $? -eq 1 && yes ok | head -n 1", "ok\n", 0},
+	{"set X=1; yes $X | head -n 1; set X=2 && yes $X | head -n 1",
+		"1\n2\n", 0},
+	{"! true || yes not | head -n 1", "not\n", 0},
+	{"false; true; false", "", 1},
+	{"exit; yes a | head -n 1", "", 0},
+	{"true && exit || yes a | head -n 1\nyes b | head -n 1", "", 0},
+	{"yes '&&' | head -n 1 && yes ';' | head -n 1", "&&\n;\n", 0},
+	{"if false || true; then\nyes a | head -n 1; yes b | head -n 1\nfi",
+		"a\nb\n", 0},
+}
+
+func TestAndOr(t *testing.T) {
+	for _, test := range andOrTests {
+		p := &Process{
+			Stdin: strings.NewReader(""),
+			Env:   make(map[string]string),
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout || status != test.status {
+			t.Errorf("%q: got %q, %v, expected %q, %v (stderr %q)",
+				test.script, stdout, status, test.stdout, test.status,
+				stderr)
+		}
+	}
+}
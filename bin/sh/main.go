@@ -9,12 +9,14 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -26,11 +28,54 @@ import (
 
 var shellPrompt = "bbos \\W $ "
 
+// Process defines the I/O streams of a command. The Flags is the
+// builtin command's own flag set. The WorkingDir is the command's
+// working directory. The Args are the positional parameters where
+// Args[0] is the shell or script name. The Env holds the shell
+// variables and the Status is the exit status of the last command.
+// The Interrupt delivers signals to the command.
+type Process struct {
+	Stdin      io.Reader
+	Stdout     io.Writer
+	Stderr     io.Writer
+	Flags      *flag.FlagSet
+	WorkingDir string
+	Args       []string
+	Env        map[string]string
+	Status     int
+	Interrupt  *Interrupt
+}
+
+// Path resolves the file name relative to the process' working
+// directory.
+func (p *Process) Path(name string) string {
+	if len(p.WorkingDir) == 0 || path.IsAbs(name) {
+		return name
+	}
+	return path.Join(p.WorkingDir, name)
+}
+
+// Builtin defines a builtin command. The command function returns
+// the command's exit status. The Usage is the command's synopsis and
+// the Help is a one-line description of the command.
 type Builtin struct {
-	Name string
-	Cmd  func(args []string)
+	Name  string
+	Cmd   func(p *Process, args []string) int
+	Usage string
+	Help  string
 }
 
+// StatusNotFound is the exit status of a command that is neither a
+// builtin nor a program.
+const StatusNotFound = 127
+
+// The process functions.
+var (
+	spawn = bbos.Spawn
+	wait  = bbos.Wait
+	kill  = bbos.Kill
+)
+
 var (
 	builtin  []Builtin
 	builtins map[string]Builtin
@@ -62,18 +107,50 @@ func CommandEscape(command string) string {
 	return reCommandEscape.ReplaceAllString(command, "\\${1}")
 }
 
-func cmd_help(args []string) {
-	fmt.Fprintf(os.Stdout, "Available commands are:\n")
+func lookupBuiltin(name string) (Builtin, bool) {
+	if builtins == nil {
+		builtins = make(map[string]Builtin)
+		for _, bi := range builtin {
+			builtins[bi.Name] = bi
+		}
+	}
+	bi, ok := builtins[name]
+	return bi, ok
+}
 
-	names := make([]string, 0, len(builtin))
-	for _, cmd := range builtin {
-		names = append(names, cmd.Name)
+// cmd_help lists the builtin commands with their descriptions. With
+// command arguments, it prints the usage and description of the
+// commands.
+func cmd_help(p *Process, args []string) int {
+	if len(args) > 1 {
+		var status int
+		for idx, name := range args[1:] {
+			bi, ok := lookupBuiltin(name)
+			if !ok {
+				fmt.Fprintf(p.Stderr, "help: no such command: %s\n", name)
+				status = 1
+				continue
+			}
+			if idx > 0 {
+				fmt.Fprintf(p.Stdout, "\n")
+			}
+			fmt.Fprintf(p.Stdout, "usage: %s\n\n", bi.Usage)
+			fmt.Fprintf(p.Stdout, "%s\n", bi.Help)
+		}
+		return status
 	}
-	sort.Strings(names)
 
-	for _, name := range names {
-		fmt.Fprintf(os.Stdout, "  %s\n", name)
+	fmt.Fprintf(p.Stdout, "Available commands are:\n")
+
+	sorted := make([]Builtin, len(builtin))
+	copy(sorted, builtin)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	for _, cmd := range sorted {
+		fmt.Fprintf(p.Stdout, "  %-8s %s\n", cmd.Name, cmd.Help)
 	}
+	return 0
 }
 
 func init() {
@@ -89,90 +166,221 @@ func init() {
 		// 	},
 		// },
 		Builtin{
-			Name: "exit",
-			Cmd: func(args []string) {
+			Name:  "exit",
+			Usage: "exit",
+			Help:  "Exit the shell.",
+			Cmd: func(p *Process, args []string) int {
 				running = false
+				return 0
 			},
 		},
 		Builtin{
-			Name: "help",
-			Cmd:  cmd_help,
+			Name:  "help",
+			Usage: "help [command]",
+			Help:  "List the builtin commands or describe command.",
+			Cmd:   cmd_help,
 		},
 	}...)
 }
 
-func readLine(in io.Reader) string {
-	var buf [1024]byte
-	var line string
-
-	for {
-		n, _ := in.Read(buf[:])
-		if n == 0 {
-			break
-		}
-		line += string(buf[:n])
-		if buf[n-1] == '\n' || buf[n-1] == '\r' {
-			break
-		}
+func main() {
+	rl := readline.NewReadline(os.Stdin, os.Stdout, os.Stderr)
+	// The lines of a multi-line paste are run as a command list.
+	rl.PasteSeparator = "; "
+	readLine := func(prompt string) (string, error) {
+		line, err := rl.Read(prompt)
+		fmt.Fprintf(os.Stdout, "\n")
+		return line, err
 	}
-	return strings.TrimSpace(line)
-}
 
-func main() {
-	builtins = make(map[string]Builtin)
-	for _, bi := range builtin {
-		builtins[bi.Name] = bi
+	p := &Process{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Args:   os.Args,
+		Env:    newEnv(),
+	}
+	wd, err := getwd()
+	if err != nil {
+		log.Fatal(err)
 	}
+	p.WorkingDir = wd
 
-	rl := readline.NewReadline(os.Stdin, os.Stdout, os.Stderr)
 	rl.Tab = func(line string) (string, []string) {
-		return tabCompletion(line)
+		return tabCompletion(p, line)
 	}
 
+	go watchInterrupts(int(os.Stdin.Fd()))
+
 	for running {
-		line, err := rl.Read(prompt())
-		fmt.Fprintf(os.Stdout, "\n")
+		reportJobs(p.Stdout)
+		line, err := readLine(prompt())
+		if errors.Is(err, readline.ErrInterrupt) {
+			// Ctrl-C cancels the line.
+			p.Status = SIGINT.ExitStatus()
+			continue
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
-		args := split(line)
-		if len(args) == 0 || len(args[0]) == 0 {
-			continue
+		// Ctrl-C interrupts the command line.
+		p.Interrupt = NewInterrupt()
+		setForeground(p.Interrupt)
+		p.Status, err = eval(p, line, readLine)
+		setForeground(nil)
+		runInterruptTrap(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
+	}
+	runExitTrap(p)
+}
+
+// eval evaluates the command line and returns the exit status of the
+// last command. The next function reads continuation lines for
+// multiline constructs. The syntax errors are returned as errors,
+// while the command errors are reported to the process' standard
+// error.
+func eval(p *Process, line string,
+	next func(prompt string) (string, error)) (int, error) {
+
+	r := &cmdReader{
+		next: next,
+	}
+	if err := r.split(line); err != nil {
+		return 1, err
+	}
+	nodes, err := parseCommands(r)
+	if err != nil {
+		return 1, err
+	}
+	return evalNodes(p, nodes)
+}
+
+// evalPipeline evaluates the pipeline command line.
+func evalPipeline(p *Process, line string,
+	next func(prompt string) (string, error)) (int, error) {
 
-		err = runCommand(args)
+	args, err := tokenize(line, p)
+	if err != nil {
+		return 1, err
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if args[0].Text == "!" {
+		// The leading ! negates the exit status of the command.
+		status, err := evalCommand(p, args[1:], next)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+			return status, err
 		}
+		if status == 0 {
+			return 1, nil
+		}
+		return 0, nil
 	}
+	return evalCommand(p, args, next)
 }
 
-func runCommand(args []string) error {
-	bi, ok := builtins[args[0]]
+// evalCommand evaluates the command pipeline.
+func evalCommand(p *Process, args []Token,
+	next func(prompt string) (string, error)) (int, error) {
+
+	if len(args) == 0 {
+		return 0, nil
+	}
+	stages, err := parsePipeline(p, args, next)
+	if err != nil {
+		return 1, err
+	}
+	return runPipeline(p, stages), nil
+}
+
+func runCommand(p *Process, args []string) (int, error) {
+	bi, ok := lookupBuiltin(args[0])
 	if ok {
-		os.Args = args
-		flag.CommandLine = flag.NewFlagSet(args[0], flag.ContinueOnError)
-		flag.CommandLine.SetOutput(os.Stdout)
-		bi.Cmd(args)
-	} else {
-		// Run as process.
-		pid, err := bbos.Spawn(args, []int{
-			int(os.Stdin.Fd()),
-			int(os.Stdout.Fd()),
-			int(os.Stderr.Fd()),
-		})
-		if err != nil {
-			return err
+		// Each command gets its own flag set so that concurrent
+		// pipeline stages do not share flags.
+		bp := &Process{
+			Stdin:      p.Stdin,
+			Stdout:     p.Stdout,
+			Stderr:     p.Stderr,
+			Flags:      flag.NewFlagSet(args[0], flag.ContinueOnError),
+			WorkingDir: p.WorkingDir,
+			Args:       p.Args,
+			Env:        p.Env,
+			Status:     p.Status,
+			Interrupt:  p.Interrupt,
 		}
-		code, err := bbos.Wait(pid)
-		if err != nil {
-			return err
+		bp.Flags.SetOutput(p.Stdout)
+		status := bi.Cmd(bp, args)
+		p.WorkingDir = bp.WorkingDir
+		if sig := p.Interrupt.Signalled(); sig != 0 {
+			status = sig.ExitStatus()
 		}
-		if code != 0 {
-			fmt.Printf("%d: Exit %d: %s\n", pid, code, args[0])
+		return status, nil
+	}
+
+	// Run as process.
+	fds, err := processFDs(p)
+	if err != nil {
+		return 1, err
+	}
+	pid, err := spawn(args, fds)
+	if errors.Is(err, bbos.ErrNotFound) {
+		fmt.Fprintf(p.Stderr, "%s: command not found\n", args[0])
+		return StatusNotFound, nil
+	} else if err != nil {
+		return 1, err
+	}
+
+	// The signals delivered to the command line terminate the
+	// process.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-p.Interrupt.Done():
+			kill(pid, int(p.Interrupt.Signalled()))
+		case <-done:
 		}
+	}()
+	code, err := wait(pid)
+	close(done)
+	if err != nil {
+		return 1, err
+	}
+	if sig := p.Interrupt.Signalled(); sig != 0 {
+		return sig.ExitStatus(), nil
 	}
-	return nil
+	if code != 0 {
+		fmt.Fprintf(p.Stdout, "%d: Exit %d: %s\n", pid, code, args[0])
+	}
+	return code, nil
+}
+
+var (
+	errCapture = errors.New("cannot capture external command output")
+	errStdin   = errors.New("cannot redirect external command input")
+)
+
+// processFDs returns the file descriptors of the process I/O
+// streams. External commands can only be connected to streams that
+// are backed by file descriptors so their output cannot be captured
+// into pipes or buffers.
+func processFDs(p *Process) ([]int, error) {
+	stdout, ok := p.Stdout.(*os.File)
+	if !ok {
+		return nil, errCapture
+	}
+	stderr, ok := p.Stderr.(*os.File)
+	if !ok {
+		return nil, errCapture
+	}
+	stdin, ok := p.Stdin.(*os.File)
+	if !ok {
+		return nil, errStdin
+	}
+	return []int{int(stdin.Fd()), int(stdout.Fd()), int(stderr.Fd())}, nil
 }
 
 func prompt() string {
@@ -219,7 +427,10 @@ func prompt() string {
 	return string(result)
 }
 
-func tabCompletion(line string) (string, []string) {
+// tabCompletion completes the last word of the line. The variable
+// references are completed from the shell variables of the process
+// and the other words from the file names.
+func tabCompletion(p *Process, line string) (string, []string) {
 	parts := split(line)
 
 	if len(parts) == 0 {
@@ -230,6 +441,10 @@ func tabCompletion(line string) (string, []string) {
 		return line, nil
 	}
 
+	if last[0] == '$' {
+		return tabEnvCompletion(p, line, parts, last)
+	}
+
 	// 	if last[0] == '@' {
 	// 		return tabSnapshotCompletion(p, line, parts, last)
 	// 	}
@@ -271,6 +486,39 @@ func tabCompletion(line string) (string, []string) {
 // 	}
 // }
 
+// tabEnvCompletion completes shell variable names in the $NAME
+// and ${NAME} forms.
+func tabEnvCompletion(p *Process, line string, parts CommandLine,
+	last string) (string, []string) {
+
+	prefix := "$"
+	var suffix string
+	if strings.HasPrefix(last, "${") {
+		prefix = "${"
+		suffix = "}"
+	}
+	name := last[len(prefix):]
+
+	var arr []string
+	for env := range p.Env {
+		if strings.HasPrefix(env, name) {
+			arr = append(arr, env)
+		}
+	}
+	sort.Strings(arr)
+
+	switch len(arr) {
+	case 0:
+		return line, nil
+	case 1:
+		parts[len(parts)-1] = prefix + arr[0] + suffix
+		return parts.String(), nil
+	default:
+		parts[len(parts)-1] = prefix + commonPrefix(arr)
+		return parts.String(), arr
+	}
+}
+
 func tabFileCompletion(line string, parts CommandLine, last string) (
 	string, []string) {
 
@@ -0,0 +1,165 @@
+//
+// main_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func TestHelp(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+		stderr string
+		status int
+	}{
+		{
+			script: "help head",
+			stdout: "usage: head [-n count] [file...]\n\n" +
+				"Print the first lines of the input.\n",
+		},
+		{
+			script: "help true false",
+			stdout: "usage: true\n\nExit with status 0.\n\n" +
+				"usage: false\n\nExit with status 1.\n",
+		},
+		{
+			script: "help nosuch",
+			stderr: "help: no such command: nosuch\n",
+			status: 1,
+		},
+		{
+			script: "help | head -n 3",
+			stdout: "Available commands are:\n" +
+				"  .        Run the commands of file in this shell.\n" +
+				"  [        Evaluate the conditional expression expr.\n",
+		},
+	}
+	for _, test := range tests {
+		p := &Process{
+			Stdin: strings.NewReader(""),
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
+
+func TestBuiltinHelp(t *testing.T) {
+	for _, bi := range builtin {
+		if len(bi.Usage) == 0 || len(bi.Help) == 0 {
+			t.Errorf("builtin %s: missing usage or help", bi.Name)
+		}
+		if !strings.HasPrefix(bi.Usage, bi.Name) {
+			t.Errorf("builtin %s: invalid usage: %s", bi.Name, bi.Usage)
+		}
+	}
+}
+
+var commandNotFoundTests = []struct {
+	spawnErr error
+	stdout   string
+	stderr   string
+}{
+	{
+		spawnErr: bbos.ErrNotFound,
+		stderr:   "nosuch: command not found\n",
+	},
+	{
+		// The program exists and exits with the status 127.
+		stdout: "7: Exit 127: nosuch\n",
+	},
+}
+
+func TestCommandNotFound(t *testing.T) {
+	savedSpawn, savedWait := spawn, wait
+	defer func() {
+		spawn, wait = savedSpawn, savedWait
+	}()
+	for _, test := range commandNotFoundTests {
+		spawn = func(argv []string, fds []int) (int, error) {
+			return 7, test.spawnErr
+		}
+		wait = func(pid int) (int, error) {
+			return StatusNotFound, nil
+		}
+		stdout, stderr := runNotFound(t)
+		if stdout != test.stdout {
+			t.Errorf("%v: stdout %q, expected %q",
+				test.spawnErr, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%v: stderr %q, expected %q",
+				test.spawnErr, stderr, test.stderr)
+		}
+	}
+}
+
+// runNotFound runs the command nosuch and checks its exit status. It
+// returns the command's standard output and error.
+func runNotFound(t *testing.T) (string, string) {
+	// Processes can only be connected to files.
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	p := &Process{
+		Stdin:  os.Stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+	status, err := evalLines(p, []string{"nosuch arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != StatusNotFound {
+		t.Errorf("status %v, expected %v", status, StatusNotFound)
+	}
+	tokens, err := tokenize("$?", p)
+	if err != nil || len(tokens) != 1 || tokens[0].Text != "127" {
+		t.Errorf("$?: got %q, %v", words(tokens), err)
+	}
+	outData, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errData, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(outData), string(errData)
+}
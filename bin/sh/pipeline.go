@@ -0,0 +1,174 @@
+//
+// pipeline.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrBrokenPipe is returned for writes to a pipeline stage whose
+// reader has exited.
+var ErrBrokenPipe = errors.New("broken pipe")
+
+// StatusBrokenPipe is the exit status of a command terminated by a
+// broken pipe. It matches the status of a command killed by SIGPIPE.
+const StatusBrokenPipe = 128 + 13
+
+// stage defines a pipeline command. The stdin overrides the standard
+// input of the command, for example, with a here-document. The
+// redirs are applied after the pipeline connections. The assigns are
+// the variable assignments preceding the command.
+type stage struct {
+	args    CommandLine
+	stdin   io.Reader
+	redirs  []*redirect
+	assigns []string
+}
+
+// parsePipeline splits the command line into pipeline stages at the
+// | operators and reads the stages' here-documents and I/O
+// redirections. The here-documents are expanded with the variables
+// of the process p.
+func parsePipeline(p *Process, args []Token,
+	next func(prompt string) (string, error)) ([]*stage, error) {
+
+	var stages []*stage
+	var start int
+
+	for idx := 0; idx <= len(args); idx++ {
+		if idx < len(args) && !(args[idx].Op && args[idx].Text == "|") {
+			continue
+		}
+		if idx == start {
+			return nil, fmt.Errorf("syntax error near `|'")
+		}
+		tokens, hd, err := parseHeredoc(args[start:idx])
+		if err != nil {
+			return nil, err
+		}
+		cmd, redirs, err := parseRedirects(tokens)
+		if err != nil {
+			return nil, err
+		}
+		cmd, assigns := parseAssignments(cmd)
+		s := &stage{
+			args:    cmd,
+			redirs:  redirs,
+			assigns: assigns,
+		}
+		if hd != nil {
+			err = hd.read(next, p.lookup)
+			if err != nil {
+				return nil, err
+			}
+			s.stdin = strings.NewReader(hd.body)
+		}
+		stages = append(stages, s)
+		start = idx + 1
+	}
+	return stages, nil
+}
+
+// runPipeline runs the pipeline stages concurrently, connecting each
+// stage's standard output to the next stage's standard input. When a
+// stage exits, its input pipe is closed so that the writing stage
+// gets ErrBrokenPipe from its next write. The stages of a multi-stage
+// pipeline run with copies of the shell variables like in subshells.
+// The function waits for all stages to exit and returns the exit
+// status of the last stage.
+func runPipeline(p *Process, stages []*stage) int {
+	var wg sync.WaitGroup
+	var in *io.PipeReader
+	var status int
+
+	stdin := p.Stdin
+	for idx, s := range stages {
+		sp := &Process{
+			Stdin:      stdin,
+			Stdout:     p.Stdout,
+			Stderr:     p.Stderr,
+			WorkingDir: p.WorkingDir,
+			Args:       p.Args,
+			Env:        p.Env,
+			Status:     p.Status,
+			Interrupt:  p.Interrupt,
+		}
+		if len(stages) > 1 {
+			sp.Env = cloneEnv(p.Env)
+		}
+		assign(sp, s.assigns, len(s.args) > 0)
+		if s.stdin != nil {
+			sp.Stdin = s.stdin
+		}
+		var out *io.PipeWriter
+		var next *io.PipeReader
+		if idx+1 < len(stages) {
+			next, out = io.Pipe()
+			sp.Stdout = out
+			stdin = next
+		}
+
+		run := func(sp *Process, s *stage, in *io.PipeReader,
+			out *io.PipeWriter) int {
+
+			var status int
+			files, err := redirectStreams(sp, s.redirs)
+			if err != nil {
+				// The command is not run if redirections fail.
+				fmt.Fprintf(sp.Stderr, "%s\n", err)
+				status = 1
+			} else if len(s.args) > 0 {
+				status, err = runCommand(sp, s.args)
+				if err != nil {
+					fmt.Fprintf(sp.Stderr, "%s: %s\n",
+						s.args[0], err)
+					status = 1
+				}
+			}
+			closeFiles(files)
+			if out != nil {
+				out.Close()
+			}
+			if in != nil {
+				in.CloseWithError(ErrBrokenPipe)
+			}
+			return status
+		}
+		if out == nil {
+			// The last stage runs in the shell's goroutine.
+			status = run(sp, s, in, out)
+			p.WorkingDir = sp.WorkingDir
+		} else {
+			wg.Add(1)
+			go func(sp *Process, s *stage, in *io.PipeReader,
+				out *io.PipeWriter) {
+				run(sp, s, in, out)
+				wg.Done()
+			}(sp, s, in, out)
+		}
+		in = next
+	}
+	wg.Wait()
+
+	return status
+}
+
+// writeError reports the write error and returns the exit status
+// for it. The broken pipe terminates the command silently.
+func writeError(p *Process, name string, err error) int {
+	if errors.Is(err, ErrBrokenPipe) {
+		return StatusBrokenPipe
+	}
+	fmt.Fprintf(p.Stderr, "%s: %s\n", name, err)
+	return 1
+}
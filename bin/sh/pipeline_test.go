@@ -0,0 +1,144 @@
+//
+// pipeline_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var pipelineTests = []struct {
+	script string
+	stdout string
+	status int
+	err    bool
+}{
+	{
+		script: "yes | head -n 3",
+		stdout: "y\ny\ny\n",
+	},
+	{
+		script: "yes hello world | cut -d o -f 2 | head -n 2",
+		stdout: " w\n w\n",
+	},
+	{
+		script: `cat <<EOF | head -n 1
+first
+second
+EOF
+`,
+		stdout: "first\n",
+	},
+	{
+		script: `yes | cat <<EOF
+heredoc
+EOF
+`,
+		stdout: "heredoc\n",
+	},
+	{
+		script: "! yes | false",
+		status: 0,
+	},
+	{
+		script: `cat <<EOF | cut -d , -f 2 | fold -w 2 | head -n 3
+a,bcd,e
+f,ghi,j
+EOF
+`,
+		stdout: "bc\nd\ngh\n",
+	},
+	{
+		script: `yes "a|b" | cut -d "|" -f 2 | head -n 1`,
+		stdout: "b\n",
+	},
+	{
+		script: "yes a\\|b|cut -d '|' -f 1|head -n 1",
+		stdout: "a\n",
+	},
+	{
+		script: "X='|'\nyes a $X head -n 1 | head -n 1",
+		stdout: "a | head -n 1\n",
+	},
+	{
+		script: "X='> out' Y='<<'\nyes $X $Y EOF | head -n 1",
+		stdout: "> out << EOF\n",
+	},
+	{
+		script: "X=1 | cat\nyes \"[$X]\" | head -n 1",
+		stdout: "[]\n",
+	},
+	{
+		script: "yes | set Y=1\nyes \"[$Y]\" | head -n 1",
+		stdout: "[]\n",
+	},
+	{
+		script: "X=1\nX=2 | yes $X | head -n 1",
+		stdout: "1\n",
+	},
+	{
+		script: "set Z=1\nyes \"[$Z]\" | head -n 1",
+		stdout: "[1]\n",
+	},
+	{
+		script: "yes |",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "yes | | head",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "yes ||",
+		status: 1,
+		err:    true,
+	},
+	{
+		script: "| head",
+		status: 1,
+		err:    true,
+	},
+}
+
+func TestPipeline(t *testing.T) {
+	p := &Process{
+		Stdin: strings.NewReader(""),
+	}
+	for _, test := range pipelineTests {
+		stdout, _, status, err := EvalCapture(p, test.script)
+		if err != nil != test.err {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout: got %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if status != test.status {
+			t.Errorf("%q: status: got %d, expected %d",
+				test.script, status, test.status)
+		}
+	}
+}
+
+func TestBrokenPipe(t *testing.T) {
+	r, w := io.Pipe()
+	p := &Process{
+		Stdout: w,
+	}
+	r.CloseWithError(ErrBrokenPipe)
+
+	status := cmd_yes(p, []string{"yes"})
+	if status != StatusBrokenPipe {
+		t.Errorf("yes: got status %d, expected %d", status, StatusBrokenPipe)
+	}
+}
@@ -0,0 +1,158 @@
+//
+// redirect.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// redirect defines an I/O redirection of a command. The name is the
+// file name or, for duplications, the duplicated file descriptor.
+// The unquoted targets &0, &1, and &2 duplicate the standard input,
+// output, and error.
+type redirect struct {
+	op   string
+	fd   int
+	name string
+	dup  bool
+}
+
+var (
+	errMissingFile = errors.New("syntax error: missing file name")
+	errReadOnly    = errors.New("read-only file system")
+)
+
+// devNull is the null device. The input redirected from it is empty
+// and the output redirected to it is discarded.
+const devNull = "/dev/null"
+
+// redirectOps maps the redirection operators to the file
+// descriptors they redirect.
+var redirectOps = map[string]int{
+	"2>>": 2,
+	"2>":  2,
+	">>":  1,
+	">":   1,
+	"<":   0,
+}
+
+// parseRedirects extracts the redirections from the command
+// arguments. The redirection operators are the operator tokens and
+// the file name is the word following the operator. The
+// here-documents must be extracted before.
+func parseRedirects(args []Token) (CommandLine, []*redirect, error) {
+	var result CommandLine
+	var redirs []*redirect
+
+	for i := 0; i < len(args); i++ {
+		fd, ok := redirectOps[args[i].Text]
+		if !ok || !args[i].Op {
+			result = append(result, args[i].Text)
+			continue
+		}
+		if i+1 >= len(args) || args[i+1].Op || len(args[i+1].Text) == 0 {
+			return nil, nil, errMissingFile
+		}
+		target := args[i+1]
+		r := &redirect{
+			op:   args[i].Text,
+			fd:   fd,
+			name: target.Text,
+		}
+		if !target.Quoted && strings.HasPrefix(target.Text, "&") {
+			r.name = target.Text[1:]
+			r.dup = true
+		}
+		redirs = append(redirs, r)
+		i++
+	}
+	return result, redirs, nil
+}
+
+// redirectStreams opens the redirections and sets the process'
+// streams. The output can be redirected only to the null device and
+// to the standard output and error since the files can't be
+// written. It returns the opened files which must be closed after
+// the command completes. If any of the redirections fail, the files
+// opened so far are closed.
+func redirectStreams(p *Process, redirs []*redirect) ([]io.Closer, error) {
+	var files []io.Closer
+
+	for _, r := range redirs {
+		var stream interface{}
+
+		if r.dup {
+			switch {
+			case r.fd == 0 && r.name == "0":
+				stream = p.Stdin
+			case r.fd > 0 && r.name == "1":
+				stream = p.Stdout
+			case r.fd > 0 && r.name == "2":
+				stream = p.Stderr
+			default:
+				err := fmt.Errorf("%s: bad file descriptor", r.name)
+				closeFiles(files)
+				return nil, err
+			}
+		} else if r.name == devNull {
+			if r.fd == 0 {
+				stream = strings.NewReader("")
+			} else {
+				stream = nullWriter{}
+			}
+		} else if r.fd > 0 {
+			// The process file system is read-only: the kernel
+			// opens files only for reading.
+			closeFiles(files)
+			return nil, fmt.Errorf("%s: %s", r.name, errReadOnly)
+		} else {
+			f, err := os.Open(p.Path(r.name))
+			if err != nil {
+				closeFiles(files)
+				var pe *os.PathError
+				if errors.As(err, &pe) {
+					err = pe.Err
+				}
+				return nil, fmt.Errorf("%s: %s", r.name, err)
+			}
+			files = append(files, f)
+			stream = f
+		}
+		switch r.fd {
+		case 0:
+			p.Stdin = stream.(io.Reader)
+		case 1:
+			p.Stdout = stream.(io.Writer)
+		case 2:
+			p.Stderr = stream.(io.Writer)
+		}
+	}
+	return files, nil
+}
+
+func closeFiles(files []io.Closer) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// nullWriter discards all data written to it. It yields the processor
+// on each write so that the commands writing to the null device in a
+// loop can be interrupted.
+type nullWriter struct{}
+
+func (w nullWriter) Write(p []byte) (int, error) {
+	runtime.Gosched()
+	return len(p), nil
+}
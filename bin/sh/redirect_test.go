@@ -0,0 +1,176 @@
+//
+// redirect_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		script string
+		input  string
+		stdout string
+		stderr string
+		status int
+		err    bool
+	}{
+		{
+			script: "yes a | head -n 1 > out",
+			stderr: "out: read-only file system\n",
+			status: 1,
+		},
+		{
+			script: "yes a | head -n 1 >>out",
+			stderr: "out: read-only file system\n",
+			status: 1,
+		},
+		{
+			script: "cat nosuch 2> out",
+			stderr: "out: read-only file system\n",
+			status: 1,
+		},
+		{
+			script: "yes a | head -n 1 > /dev/null",
+		},
+		{
+			script: "cat nosuch 2>/dev/null",
+			status: 1,
+		},
+		{
+			script: "cat < /dev/null",
+		},
+		{
+			script: "tac < in",
+			input:  "x\ny\n",
+			stdout: "y\nx\n",
+		},
+		{
+			script: "cat <<EOF | tac < in\na\nb\nEOF",
+			input:  "c\n",
+			stdout: "c\n",
+		},
+		{
+			script: "cat nosuch 2>&1",
+			status: 1,
+			stdout: "cat: ",
+		},
+		{
+			script: "> /dev/null",
+		},
+		{
+			script: "cat >",
+			err:    true,
+		},
+		{
+			script: "cat < nosuch",
+			stderr: "nosuch: ",
+			status: 1,
+		},
+		{
+			script: "yes a | head -n 1>/dev/null",
+		},
+		{
+			script: `yes "a>b" | cut -d ">" -f 2 | head -n 1`,
+			stdout: "b\n",
+		},
+		{
+			script: `yes 'a<b' | cut -d \< -f 1 | head -n 1`,
+			stdout: "a\n",
+		},
+		{
+			script: `yes "2>" '>>' | head -n 1`,
+			stdout: "2> >>\n",
+		},
+		{
+			script: `yes "<<" | head -n 1`,
+			stdout: "<<\n",
+		},
+		{
+			script: `test "<" = "<"`,
+		},
+		{
+			script: `test ">" = "<"`,
+			status: 1,
+		},
+		{
+			script: "cat > | tac",
+			err:    true,
+		},
+		{
+			script: "yes a | head -n 1 >'&2'",
+			stderr: "&2: read-only file system\n",
+			status: 1,
+		},
+		{
+			script: "yes a | head -n 1 >&2",
+			stderr: "a\n",
+		},
+		{
+			script: "cat <&0",
+		},
+		{
+			script: "cat <&1",
+			stderr: "1: bad file descriptor",
+			status: 1,
+		},
+		{
+			script: "cat >&3",
+			stderr: "3: bad file descriptor",
+			status: 1,
+		},
+	}
+	for _, test := range tests {
+		tmp, err := ioutil.TempDir("", "redirect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(test.input) > 0 {
+			err = ioutil.WriteFile(path.Join(tmp, "in"),
+				[]byte(test.input), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		p := &Process{
+			Stdin:      strings.NewReader(""),
+			WorkingDir: tmp,
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		os.RemoveAll(tmp)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.script)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if !strings.HasPrefix(stdout, test.stdout) ||
+			(len(test.stdout) == 0 && len(stdout) > 0) {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if !strings.HasPrefix(stderr, test.stderr) ||
+			(len(test.stderr) == 0 && len(stderr) > 0) {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
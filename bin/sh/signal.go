@@ -0,0 +1,372 @@
+//
+// signal.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name:  "kill",
+			Usage: "kill [-s sig | -sig] pid|%n... | kill -l",
+			Help:  "Send a signal to the shell or to a job.",
+			Cmd:   cmd_kill,
+		},
+		Builtin{
+			Name:  "trap",
+			Usage: "trap [action sig...]",
+			Help:  "Set or list the signal handlers.",
+			Cmd:   cmd_trap,
+		},
+	}...)
+}
+
+// Signal defines the signal numbers. The signal 0 is the EXIT
+// pseudo-signal of the trap command.
+type Signal int
+
+// Signals.
+const (
+	SIGEXIT Signal = 0
+	SIGHUP  Signal = 1
+	SIGINT  Signal = 2
+	SIGKILL Signal = 9
+	SIGTERM Signal = 15
+)
+
+var signalNames = map[Signal]string{
+	SIGEXIT: "EXIT",
+	SIGHUP:  "HUP",
+	SIGINT:  "INT",
+	SIGKILL: "KILL",
+	SIGTERM: "TERM",
+}
+
+func (sig Signal) String() string {
+	name, ok := signalNames[sig]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Signal %d}", sig)
+}
+
+// ExitStatus returns the exit status of a command terminated by the
+// signal.
+func (sig Signal) ExitStatus() int {
+	return 128 + int(sig)
+}
+
+// parseSignal parses the signal name or number. The names are
+// accepted with and without the SIG prefix.
+func parseSignal(arg string) (Signal, bool) {
+	num, err := strconv.Atoi(arg)
+	if err == nil {
+		_, ok := signalNames[Signal(num)]
+		return Signal(num), ok
+	}
+	name := strings.TrimPrefix(strings.ToUpper(arg), "SIG")
+	for sig, n := range signalNames {
+		if n == name {
+			return sig, true
+		}
+	}
+	return 0, false
+}
+
+// Interrupt delivers a signal to running commands. The commands
+// select on the Done channel and terminate when it is closed.
+type Interrupt struct {
+	mutex  sync.Mutex
+	signal Signal
+	done   chan struct{}
+}
+
+// NewInterrupt creates a new interrupt.
+func NewInterrupt() *Interrupt {
+	return &Interrupt{
+		done: make(chan struct{}),
+	}
+}
+
+// Signal delivers the signal. Only the first signal is recorded.
+func (i *Interrupt) Signal(sig Signal) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.signal == 0 {
+		i.signal = sig
+		close(i.done)
+	}
+}
+
+// Done returns a channel that is closed when a signal is
+// delivered. The channel of the nil interrupt is never closed.
+func (i *Interrupt) Done() <-chan struct{} {
+	if i == nil {
+		return nil
+	}
+	return i.done
+}
+
+// Signalled returns the delivered signal or 0 if no signal has been
+// delivered.
+func (i *Interrupt) Signalled() Signal {
+	if i == nil {
+		return 0
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.signal
+}
+
+// interrupted tests if a signal has been delivered to the process.
+func (p *Process) interrupted() bool {
+	return p.Interrupt.Signalled() != 0
+}
+
+var (
+	foregroundMutex sync.Mutex
+	foreground      *Interrupt
+)
+
+// setForeground sets the interrupt of the foreground command line.
+func setForeground(intr *Interrupt) {
+	foregroundMutex.Lock()
+	foreground = intr
+	foregroundMutex.Unlock()
+}
+
+// interruptForeground delivers SIGINT to the foreground command
+// line. It does nothing if no command line is running, if the command
+// line has already been interrupted, or if the INT trap ignores the
+// signal. The INT trap action runs with runInterruptTrap after the
+// interrupted command line has stopped.
+func interruptForeground() {
+	foregroundMutex.Lock()
+	defer foregroundMutex.Unlock()
+
+	if foreground == nil {
+		return
+	}
+	if action, ok := traps[SIGINT]; ok && len(action) == 0 {
+		return
+	}
+	foreground.Signal(SIGINT)
+}
+
+// runInterruptTrap runs the INT trap action if the command line was
+// interrupted with SIGINT.
+func runInterruptTrap(p *Process) {
+	if p.Interrupt.Signalled() != SIGINT {
+		return
+	}
+	if action := traps[SIGINT]; len(action) > 0 {
+		runTrap(p, action)
+	}
+}
+
+// watchInterrupts delivers the Ctrl-C interrupts of the terminal fd
+// to the foreground command line.
+func watchInterrupts(fd int) {
+	for bbos.WaitInterrupt(fd) == nil {
+		interruptForeground()
+	}
+}
+
+var (
+	// shellPID is the process ID of the shell, expanded by $$.
+	shellPID = os.Getpid()
+
+	// traps maps signals to their trap actions. An empty action
+	// ignores the signal.
+	traps = make(map[Signal]string)
+
+	// killed is set when the shell is terminated with SIGKILL and
+	// the EXIT trap must not run.
+	killed bool
+)
+
+// runTrap evaluates the trap action. The action does not change the
+// exit status of the interrupted command and it runs also after the
+// command line has been interrupted.
+func runTrap(p *Process, action string) {
+	tp := *p
+	tp.Interrupt = nil
+	_, err := eval(&tp, action, func(prompt string) (string, error) {
+		return "", io.EOF
+	})
+	if err != nil {
+		fmt.Fprintf(p.Stderr, "trap: %s\n", err)
+	}
+}
+
+// runExitTrap runs the EXIT trap unless the shell was killed. The
+// trap runs also when the shell was terminated by exit or by a
+// signal.
+func runExitTrap(p *Process) {
+	action, ok := traps[SIGEXIT]
+	if !ok || killed {
+		return
+	}
+	delete(traps, SIGEXIT)
+	if len(action) > 0 {
+		running = true
+		runTrap(p, action)
+		running = false
+	}
+}
+
+// signalShell delivers the signal to the shell. The trapped signals
+// run their actions and the other signals terminate the shell. The
+// SIGKILL can't be trapped.
+func signalShell(p *Process, sig Signal) int {
+	if sig == SIGKILL {
+		killed = true
+	} else if action, ok := traps[sig]; ok {
+		if len(action) > 0 {
+			runTrap(p, action)
+		}
+		return 0
+	}
+	running = false
+	return sig.ExitStatus()
+}
+
+// cmd_kill sends the signal to the shell or to the background
+// jobs. The jobs are specified as %n or by their process IDs. The
+// signal defaults to SIGTERM.
+func cmd_kill(p *Process, args []string) int {
+	sig := SIGTERM
+	args = args[1:]
+	if len(args) > 0 && args[0] == "-l" {
+		var sigs []int
+		for s := range signalNames {
+			if s != SIGEXIT {
+				sigs = append(sigs, int(s))
+			}
+		}
+		sort.Ints(sigs)
+		for _, s := range sigs {
+			fmt.Fprintf(p.Stdout, "%2d) SIG%s\n", s, Signal(s))
+		}
+		return 0
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name := args[0][1:]
+		args = args[1:]
+		if name == "s" {
+			if len(args) == 0 {
+				fmt.Fprintf(p.Stderr, "kill: -s requires an argument\n")
+				return 2
+			}
+			name = args[0]
+			args = args[1:]
+		}
+		s, ok := parseSignal(name)
+		if !ok || s == SIGEXIT {
+			fmt.Fprintf(p.Stderr,
+				"kill: %s: invalid signal specification\n", name)
+			return 1
+		}
+		sig = s
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(p.Stderr, "usage: kill [-s sig | -sig] pid|%%n...\n")
+		return 2
+	}
+
+	var status int
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "%") {
+			idx := findJob(arg)
+			if idx < 0 {
+				fmt.Fprintf(p.Stderr, "kill: %s: no such job\n", arg)
+				status = 1
+				continue
+			}
+			jobs[idx].interrupt.Signal(sig)
+			continue
+		}
+		pid, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(p.Stderr, "kill: %s: invalid process id\n", arg)
+			status = 1
+			continue
+		}
+		if pid != shellPID {
+			idx := findJobPID(pid)
+			if idx < 0 {
+				fmt.Fprintf(p.Stderr, "kill: %d: no such process\n", pid)
+				status = 1
+				continue
+			}
+			jobs[idx].interrupt.Signal(sig)
+			continue
+		}
+		if s := signalShell(p, sig); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+// cmd_trap sets the actions for the signals. The action - resets the
+// signals to their default actions and the empty action ignores
+// them. Without arguments, it lists the traps.
+func cmd_trap(p *Process, args []string) int {
+	if len(args) < 2 {
+		var sigs []int
+		for s := range traps {
+			sigs = append(sigs, int(s))
+		}
+		sort.Ints(sigs)
+		for _, s := range sigs {
+			fmt.Fprintf(p.Stdout, "trap -- '%s' %s\n",
+				traps[Signal(s)], Signal(s))
+		}
+		return 0
+	}
+	if len(args) < 3 {
+		fmt.Fprintf(p.Stderr, "usage: trap [action sig...]\n")
+		return 2
+	}
+	action := args[1]
+	var status int
+	for _, arg := range args[2:] {
+		sig, ok := parseSignal(arg)
+		if !ok {
+			fmt.Fprintf(p.Stderr,
+				"trap: %s: invalid signal specification\n", arg)
+			status = 1
+			continue
+		}
+		if sig == SIGKILL {
+			fmt.Fprintf(p.Stderr, "trap: %s: signal can't be trapped\n",
+				arg)
+			status = 1
+			continue
+		}
+		if action == "-" {
+			delete(traps, sig)
+		} else {
+			traps[sig] = action
+		}
+	}
+	return status
+}
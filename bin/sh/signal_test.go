@@ -0,0 +1,231 @@
+//
+// signal_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var signalTests = []struct {
+	script string
+	stdout string
+	stderr string
+	status int
+}{
+	{
+		script: "trap 'yes caught | head -n 1' INT\n" +
+			"kill -INT $$\nyes after | head -n 1",
+		stdout: "caught\nafter\n",
+	},
+	{
+		script: "trap 'yes caught | head -n 1' INT TERM\n" +
+			"kill -9 $$\nyes after | head -n 1",
+		status: 137,
+	},
+	{
+		script: "kill -SIGINT $$\nyes after | head -n 1",
+		status: 130,
+	},
+	{
+		script: "trap '' TERM\nkill $$\nyes after | head -n 1",
+		stdout: "after\n",
+	},
+	{
+		script: "trap 'yes x | head -n 1' 2\ntrap - INT\nkill -s INT $$",
+		status: 130,
+	},
+	{
+		script: "trap 'yes bye | head -n 1' EXIT\ntrue",
+		stdout: "bye\n",
+	},
+	{
+		script: "trap 'yes bye | head -n 1' EXIT\nkill -TERM $$",
+		stdout: "bye\n",
+		status: 143,
+	},
+	{
+		script: "trap 'yes bye | head -n 1' EXIT\nkill -KILL $$",
+		status: 137,
+	},
+	{
+		script: "sleep 10 &\nkill -TERM %1\nfg",
+		stdout: "sleep 10\n",
+		stderr: "[1]\n",
+		status: 143,
+	},
+	{
+		script: "sleep 10 | cat &\nkill -s HUP %1\nfg",
+		stdout: "sleep 10 | cat\n",
+		stderr: "[1]\n",
+		status: 129,
+	},
+	{
+		// The jobs are signalled by their process IDs.
+		script: "sleep 10 &\nkill -INT $!\nfg",
+		stdout: "sleep 10\n",
+		stderr: "[1]\n",
+		status: 130,
+	},
+
+	{
+		script: "trap x INT\ntrap '' TERM\ntrap",
+		stdout: "trap -- 'x' INT\ntrap -- '' TERM\n",
+	},
+	{
+		script: "kill -l",
+		stdout: " 1) SIGHUP\n 2) SIGINT\n 9) SIGKILL\n15) SIGTERM\n",
+	},
+	{
+		script: "kill -FOO $$",
+		stderr: "kill: FOO: invalid signal specification\n",
+		status: 1,
+	},
+	{
+		script: "kill %3",
+		stderr: "kill: %3: no such job\n",
+		status: 1,
+	},
+	{
+		script: "trap x KILL",
+		stderr: "trap: KILL: signal can't be trapped\n",
+		status: 1,
+	},
+}
+
+func TestSignal(t *testing.T) {
+	defer func() {
+		jobs = nil
+	}()
+	for _, test := range signalTests {
+		jobs = nil
+		p := &Process{
+			Stdin: strings.NewReader(""),
+			Env:   make(map[string]string),
+		}
+		stdout, stderr, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if stderr != test.stderr {
+			t.Errorf("%q: stderr %q, expected %q",
+				test.script, stderr, test.stderr)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+	}
+}
+
+func TestInterruptForeground(t *testing.T) {
+	// Without a foreground command line, the interrupt is ignored.
+	interruptForeground()
+
+	p := &Process{
+		Stdin:     strings.NewReader(""),
+		Env:       make(map[string]string),
+		Interrupt: NewInterrupt(),
+	}
+	setForeground(p.Interrupt)
+	defer setForeground(nil)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		interruptForeground()
+		// The second interrupt is a no-op.
+		interruptForeground()
+	}()
+	script := "trap 'yes bye | head -n 1' EXIT\n" +
+		"sleep 10; yes a | head -n 1\nyes b | head -n 1"
+	start := time.Now()
+	stdout, _, status, err := EvalCapture(p, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("interrupt took %v", elapsed)
+	}
+	if status != 130 || stdout != "bye\n" {
+		t.Errorf("got %q, %v, expected \"bye\\n\", 130", stdout, status)
+	}
+	if sig := p.Interrupt.Signalled(); sig != SIGINT {
+		t.Errorf("signal %v, expected %v", sig, SIGINT)
+	}
+}
+
+var interruptTrapTests = []struct {
+	script string
+	stdout string
+	status int
+	signal Signal
+}{
+	{
+		script: "trap 'yes trapped | head -n 1' INT\n" +
+			"sleep 10; yes a | head -n 1",
+		stdout: "trapped\n",
+		status: 130,
+		signal: SIGINT,
+	},
+	{
+		script: "trap '' INT\nsleep 0.2; yes a | head -n 1",
+		stdout: "a\n",
+	},
+	{
+		script: "trap 'yes trapped | head -n 1' INT\ntrap - INT\n" +
+			"sleep 10; yes a | head -n 1",
+		status: 130,
+		signal: SIGINT,
+	},
+}
+
+func TestInterruptForegroundTrap(t *testing.T) {
+	defer setForeground(nil)
+
+	for _, test := range interruptTrapTests {
+		p := &Process{
+			Stdin:     strings.NewReader(""),
+			Env:       make(map[string]string),
+			Interrupt: NewInterrupt(),
+		}
+		setForeground(p.Interrupt)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			interruptForeground()
+		}()
+		start := time.Now()
+		stdout, _, status, err := EvalCapture(p, test.script)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.script, err)
+			continue
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%q: interrupt took %v", test.script, elapsed)
+		}
+		if stdout != test.stdout {
+			t.Errorf("%q: stdout %q, expected %q",
+				test.script, stdout, test.stdout)
+		}
+		if status != test.status {
+			t.Errorf("%q: status %v, expected %v",
+				test.script, status, test.status)
+		}
+		if sig := p.Interrupt.Signalled(); sig != test.signal {
+			t.Errorf("%q: signal %v, expected %v",
+				test.script, sig, test.signal)
+		}
+	}
+}
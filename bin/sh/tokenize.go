@@ -0,0 +1,261 @@
+//
+// tokenize.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Token defines a word or an operator of the command line. The
+// operators are recognized only from unquoted text so the quoted
+// operator characters and the expansions are always words. The
+// Quoted is set if any part of the word was quoted or escaped.
+type Token struct {
+	Text   string
+	Op     bool
+	Quoted bool
+}
+
+// operators lists the operators, the longest first.
+var operators = []string{
+	"<<-", "<<", "2>>", "2>", ">>", ">", "<", "|",
+}
+
+// operator returns the operator that prefixes s, or an empty string
+// if s does not start with an operator. The 2> and 2>> operators are
+// recognized only at the beginning of a word.
+func operator(s string, inWord bool) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) && !(inWord && op[0] == '2') {
+			return op
+		}
+	}
+	return ""
+}
+
+// words returns the texts of the tokens.
+func words(tokens []Token) CommandLine {
+	var result CommandLine
+	for _, t := range tokens {
+		result = append(result, t.Text)
+	}
+	return result
+}
+
+// tokenize splits the command line into words and operators. The
+// words are separated by unquoted whitespace and operators. The
+// unquoted | is the pipeline operator, << and <<- are the
+// here-document operators, and <, >, >>, 2>, and 2>> are the
+// redirection operators. The single quotes preserve the literal
+// value of all characters between them. The double quotes preserve
+// the literal value of all characters except the backslash which
+// escapes the characters \, ", $, and `. The unquoted backslash
+// preserves the literal value of the next character. The
+// here-document delimiters are not expanded and their Quoted flag
+// controls the expansion of the here-document body.
+//
+// The positional parameters $0, $1, ..., the special parameters, and
+// the shell variables of the process p are expanded in unquoted and
+// double-quoted text. The unquoted expansions are split into fields
+// at whitespace. The process can be nil.
+func tokenize(line string, p *Process) ([]Token, error) {
+	if p == nil {
+		p = new(Process)
+	}
+	var result []Token
+	var word strings.Builder
+	var inWord bool
+	var quoted bool
+	var escaped bool
+	var raw bool
+
+	flush := func() {
+		if word.Len() > 0 || quoted {
+			result = append(result, Token{
+				Text:   word.String(),
+				Quoted: quoted || escaped,
+			})
+		}
+		word.Reset()
+		inWord = false
+		quoted = false
+		escaped = false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == ' ' || c == '\t' {
+			if inWord {
+				flush()
+			}
+			continue
+		}
+		if op := operator(line[i:], inWord); len(op) > 0 {
+			if inWord {
+				flush()
+			}
+			result = append(result, Token{
+				Text: op,
+				Op:   true,
+			})
+			i += len(op) - 1
+			continue
+		}
+		if !inWord {
+			inWord = true
+			if len(result) > 0 {
+				last := result[len(result)-1]
+				raw = last.Op && strings.HasPrefix(last.Text, "<<")
+			}
+		}
+		switch c {
+		case '\\':
+			if i+1 >= len(line) {
+				return nil, fmt.Errorf("syntax error: unexpected end of line")
+			}
+			escaped = true
+			i++
+			word.WriteByte(line[i])
+
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error: unterminated quote `''")
+			}
+			word.WriteString(line[i+1 : i+end+1])
+			quoted = true
+			i += end + 1
+
+		case '"':
+			start := i
+			wasQuoted := quoted
+			quoted = true
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil,
+						fmt.Errorf("syntax error: unterminated quote `\"'")
+				}
+				if line[i] == '"' {
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) &&
+					strings.IndexByte("\\\"$`", line[i+1]) >= 0 {
+					i++
+				} else if line[i] == '$' && !raw {
+					n, values, ok := expandParam(line[i:], p,
+						true)
+					if ok {
+						for idx, v := range values {
+							if idx > 0 {
+								flush()
+								inWord = true
+								quoted = true
+							}
+							word.WriteString(v)
+						}
+						i += n - 1
+						continue
+					}
+				}
+				word.WriteByte(line[i])
+			}
+			// "$@" without positional parameters expands to nothing.
+			if !raw && line[start:i+1] == `"$@"` && len(p.Args) <= 1 {
+				quoted = wasQuoted
+			}
+
+		case '$':
+			if raw {
+				word.WriteByte(c)
+				break
+			}
+			n, values, ok := expandParam(line[i:], p, false)
+			if !ok {
+				word.WriteByte(c)
+				break
+			}
+			var fields []string
+			for _, v := range values {
+				fields = append(fields, strings.Fields(v)...)
+			}
+			for idx, f := range fields {
+				if idx > 0 {
+					flush()
+					inWord = true
+				}
+				word.WriteString(f)
+			}
+			i += n - 1
+
+		default:
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		flush()
+	}
+	return result, nil
+}
+
+// expandParam expands the parameter or variable at the beginning of
+// s. It returns the length of the parameter reference, the expanded
+// values, and a boolean success status. The "$*" is joined into one
+// value when quoted. The undefined variables expand to empty values.
+func expandParam(s string, p *Process, quoted bool) (
+	int, []string, bool) {
+
+	if len(s) < 2 {
+		return 0, nil, false
+	}
+	name := s[1:2]
+	n := 2
+	if s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, nil, false
+		}
+		name = s[2:end]
+		n = end + 1
+	} else if isName(name) {
+		for n < len(s) && isName(s[1:n+1]) {
+			n++
+		}
+		name = s[1:n]
+	}
+	var args []string
+	if len(p.Args) > 1 {
+		args = p.Args[1:]
+	}
+	switch name {
+	case "#", "?", "$", "!":
+		return n, []string{p.lookup(name)}, true
+
+	case "@":
+		return n, args, true
+
+	case "*":
+		if quoted {
+			return n, []string{strings.Join(args, " ")}, true
+		}
+		return n, args, true
+	}
+	if isName(name) {
+		return n, []string{p.Getenv(name)}, true
+	}
+	if len(name) == 0 {
+		return 0, nil, false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return 0, nil, false
+		}
+	}
+	return n, []string{p.lookup(name)}, true
+}
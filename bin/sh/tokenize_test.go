@@ -0,0 +1,227 @@
+//
+// tokenize_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var tokenizeTests = []struct {
+	line   string
+	tokens []string
+	err    bool
+}{
+	{"", nil, false},
+	{"   ", nil, false},
+	{"ls", []string{"ls"}, false},
+	{"  ls   -l\t/tmp  ", []string{"ls", "-l", "/tmp"}, false},
+	{`alert "hello world"`, []string{"alert", "hello world"}, false},
+	{`alert 'hello world'`, []string{"alert", "hello world"}, false},
+	{`echo hello\ world`, []string{"echo", "hello world"}, false},
+	{`echo "a 'b' c"`, []string{"echo", "a 'b' c"}, false},
+	{`echo 'a "b" c'`, []string{"echo", `a "b" c`}, false},
+	{`echo 'a\b'`, []string{"echo", `a\b`}, false},
+	{`echo "a\"b\\c\d"`, []string{"echo", `a"b\c\d`}, false},
+	{`echo "\$HOME"`, []string{"echo", "$HOME"}, false},
+	{`echo pre"mid dle"'po st'`, []string{"echo", "premid dlepo st"}, false},
+	{`echo "" ''`, []string{"echo", "", ""}, false},
+	{`echo \'`, []string{"echo", "'"}, false},
+	{`echo a\\b`, []string{"echo", `a\b`}, false},
+
+	// Here-document operators.
+	{`cat <<'EOF'`, []string{"cat", "<<", "EOF"}, false},
+	{`cat <<"EOF"`, []string{"cat", "<<", "EOF"}, false},
+	{`cat << \EOF`, []string{"cat", "<<", "EOF"}, false},
+	{`cat <<- 'E O'`, []string{"cat", "<<-", "E O"}, false},
+	{`cat <<EOF|tac`, []string{"cat", "<<", "EOF", "|", "tac"}, false},
+
+	// Errors.
+	{`echo "hello`, nil, true},
+	{`echo 'hello`, nil, true},
+	{`echo "a\"`, nil, true},
+	{`echo hello\`, nil, true},
+}
+
+var paramTests = []struct {
+	line   string
+	tokens []string
+}{
+	{"echo $# $0", []string{"echo", "3", "script"}},
+	{"echo $2 ${3}", []string{"echo", "b", "c", "d"}},
+	{`echo "$2" '$2' \$2`, []string{"echo", "b c", "$2", "$2"}},
+	{`echo $4 "$4" ${10}x`, []string{"echo", "", "x"}},
+	{`echo "$@"`, []string{"echo", "a", "b c", "d"}},
+	{`echo "x$@y"`, []string{"echo", "xa", "b c", "dy"}},
+	{`echo $@`, []string{"echo", "a", "b", "c", "d"}},
+	{`echo "$*"`, []string{"echo", "a b c d"}},
+	{`echo $ "$" $- x$`, []string{"echo", "$", "$", "$-", "x$"}},
+	{`cat <<$1`, []string{"cat", "<<", "$1"}},
+}
+
+func TestPositionalParams(t *testing.T) {
+	p := &Process{
+		Args: []string{"script", "a", "b c", "d"},
+	}
+	for _, test := range paramTests {
+		tokens, err := tokenize(test.line, p)
+		if err != nil {
+			t.Errorf("tokenize(%q) failed: %s", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual([]string(words(tokens)), test.tokens) {
+			t.Errorf("tokenize(%q): got %q, expected %q",
+				test.line, words(tokens), test.tokens)
+		}
+	}
+	p.Args = []string{"script"}
+	tokens, err := tokenize(`echo "$@"`, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string(words(tokens)), []string{"echo"}) {
+		t.Errorf(`"$@" without parameters: got %q`, words(tokens))
+	}
+}
+
+var variableTests = []struct {
+	line   string
+	tokens []string
+}{
+	{`echo $HOME ${HOME}x "$HOME"`,
+		[]string{"echo", "/home", "/homex", "/home"}},
+	{`echo $HOME/bin $A_1.c`, []string{"echo", "/home/bin", "1.c"}},
+	{`echo $UNDEF x "$UNDEF"`, []string{"echo", "x", ""}},
+	{`echo $SPACED "$SPACED"`, []string{"echo", "a", "b", " a  b "}},
+	{`echo '$HOME' \$HOME "\$HOME"`,
+		[]string{"echo", "$HOME", "$HOME", "$HOME"}},
+	{`echo $? "$?"`, []string{"echo", "3", "3"}},
+}
+
+func TestVariables(t *testing.T) {
+	p := &Process{
+		Env: map[string]string{
+			"HOME":   "/home",
+			"A_1":    "1",
+			"SPACED": " a  b ",
+		},
+		Status: 3,
+	}
+	for _, test := range variableTests {
+		tokens, err := tokenize(test.line, p)
+		if err != nil {
+			t.Errorf("tokenize(%q) failed: %s", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual([]string(words(tokens)), test.tokens) {
+			t.Errorf("tokenize(%q): got %q, expected %q",
+				test.line, words(tokens), test.tokens)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	for _, test := range tokenizeTests {
+		tokens, err := tokenize(test.line, nil)
+		if err != nil {
+			if !test.err {
+				t.Errorf("tokenize(%q) failed: %s", test.line, err)
+			}
+			continue
+		}
+		if test.err {
+			t.Errorf("tokenize(%q) succeeded: %q", test.line,
+				words(tokens))
+			continue
+		}
+		if !reflect.DeepEqual([]string(words(tokens)), test.tokens) {
+			t.Errorf("tokenize(%q): got %q, expected %q",
+				test.line, words(tokens), test.tokens)
+		}
+	}
+}
+
+var operatorTests = []struct {
+	line   string
+	tokens []Token
+}{
+	{"a|b", []Token{{Text: "a"}, {Text: "|", Op: true}, {Text: "b"}}},
+	{"a | b", []Token{{Text: "a"}, {Text: "|", Op: true}, {Text: "b"}}},
+	{`a "|" '|' \| x"|"y`, []Token{
+		{Text: "a"},
+		{Text: "|", Quoted: true},
+		{Text: "|", Quoted: true},
+		{Text: "|", Quoted: true},
+		{Text: "x|y", Quoted: true},
+	}},
+	{`cat <<EOF "<<" \<\<`, []Token{
+		{Text: "cat"},
+		{Text: "<<", Op: true},
+		{Text: "EOF"},
+		{Text: "<<", Quoted: true},
+		{Text: "<<", Quoted: true},
+	}},
+	{`cat <<-"E"OF`, []Token{
+		{Text: "cat"},
+		{Text: "<<-", Op: true},
+		{Text: "EOF", Quoted: true},
+	}},
+	{`cut -d ">" 2>&1 a2>b >>c <d`, []Token{
+		{Text: "cut"},
+		{Text: "-d"},
+		{Text: ">", Quoted: true},
+		{Text: "2>", Op: true},
+		{Text: "&1"},
+		{Text: "a2"},
+		{Text: ">", Op: true},
+		{Text: "b"},
+		{Text: ">>", Op: true},
+		{Text: "c"},
+		{Text: "<", Op: true},
+		{Text: "d"},
+	}},
+}
+
+func TestOperators(t *testing.T) {
+	for _, test := range operatorTests {
+		tokens, err := tokenize(test.line, nil)
+		if err != nil {
+			t.Errorf("tokenize(%q) failed: %s", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("tokenize(%q): got %v, expected %v",
+				test.line, tokens, test.tokens)
+		}
+	}
+}
+
+func TestExpandedOperators(t *testing.T) {
+	p := &Process{
+		Env: map[string]string{
+			"PIPE":  "|",
+			"REDIR": "2> out",
+			"HERE":  "<<EOF",
+		},
+	}
+	tokens, err := tokenize(`a $PIPE $REDIR "$HERE"`, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Token{
+		{Text: "a"},
+		{Text: "|"},
+		{Text: "2>"},
+		{Text: "out"},
+		{Text: "<<EOF", Quoted: true},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("got %v, expected %v", tokens, expected)
+	}
+}
@@ -12,11 +12,13 @@ package main
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"syscall"
 
 	"github.com/gorilla/websocket"
 	"github.com/markkurossi/blackbox-os/lib/encoding"
@@ -29,6 +31,7 @@ func main() {
 	flag.Parse()
 
 	http.HandleFunc("/proxy", proxy)
+	http.HandleFunc("/resolve", resolve)
 	http.Handle("/", http.FileServer(http.Dir(*directory)))
 
 	log.Printf("Serving %s on HTTP: %s\n", *directory, *addr)
@@ -51,25 +54,41 @@ func proxy(w http.ResponseWriter, r *http.Request) {
 
 	_, msg, err := ws.ReadMessage()
 	if err != nil {
-		sendStatus(ws, false,
+		sendStatus(ws, wsproxy.ErrorInvalid,
 			fmt.Sprintf("Failed to read dial message: %s", err))
 		return
 	}
 	dial := new(wsproxy.Dial)
 	err = encoding.Unmarshal(bytes.NewReader(msg), dial)
 	if err != nil {
-		sendStatus(ws, false, fmt.Sprintf("Invalid dial message: %s", err))
+		sendStatus(ws, wsproxy.ErrorInvalid,
+			fmt.Sprintf("Invalid dial message: %s", err))
+		return
+	}
+	network := dial.Network
+	switch network {
+	case "":
+		network = "tcp"
+	case "tcp", "udp":
+	default:
+		sendStatus(ws, wsproxy.ErrorInvalid,
+			fmt.Sprintf("Unsupported network: %s", network))
 		return
 	}
 
-	log.Printf("New connection to %s\n", dial.Addr)
+	log.Printf("New %s connection to %s\n", network, dial.Addr)
 
-	c, err := net.DialTimeout("tcp", dial.Addr, dial.Timeout)
+	c, err := net.DialTimeout(network, dial.Addr, dial.Timeout)
 	if err != nil {
-		sendStatus(ws, false, err.Error())
+		sendStatus(ws, errorCode(err), err.Error())
 		return
 	}
-	err = sendStatus(ws, true, "")
+	// Each UDP read returns one datagram which is forwarded in its
+	// own data frame, and each data frame is written as one
+	// datagram. The urgent data is a TCP feature.
+	urgent := network == "tcp" && urgentSupported
+	compress := dial.Compress
+	err = sendConnected(ws, urgent, compress)
 	if err != nil {
 		log.Printf("Failed to send connect message: %s\n", err)
 		return
@@ -86,7 +105,8 @@ func proxy(w http.ResponseWriter, r *http.Request) {
 			}
 			fmt.Printf("TCP->WS:\n%s", hex.Dump(buf[:n]))
 
-			err = ws.WriteMessage(websocket.BinaryMessage, buf[:n])
+			err = ws.WriteMessage(websocket.BinaryMessage,
+				wsproxy.DataFrame(buf[:n], compress))
 			if err != nil {
 				log.Printf("WebSocket write failed: %s\n", err)
 				ws.Close()
@@ -101,21 +121,128 @@ func proxy(w http.ResponseWriter, r *http.Request) {
 			log.Printf("WebSocket read failed: %s\n", err)
 			break
 		}
-		fmt.Printf("WS->TCP:\n%s", hex.Dump(message))
-		_, err = c.Write(message)
+		t, payload, err := wsproxy.ParseFrame(message)
 		if err != nil {
-			log.Printf("TCP write failed: %s\n", err)
+			log.Printf("Invalid frame: %s\n", err)
 			break
 		}
+		if t == wsproxy.FrameDeflate && compress {
+			payload, err = wsproxy.Inflate(payload)
+			if err != nil {
+				log.Printf("Invalid compressed frame: %s\n", err)
+				break
+			}
+			t = wsproxy.FrameData
+		}
+		switch t {
+		case wsproxy.FrameData:
+			fmt.Printf("WS->TCP:\n%s", hex.Dump(payload))
+			_, err = c.Write(payload)
+			if err != nil {
+				log.Printf("TCP write failed: %s\n", err)
+				return
+			}
+
+		case wsproxy.FrameUrgent:
+			fmt.Printf("WS->TCP urgent:\n%s", hex.Dump(payload))
+			err = writeUrgent(c, payload)
+			if err != nil {
+				log.Printf("TCP urgent write failed: %s\n", err)
+				return
+			}
+
+		case wsproxy.FrameClose:
+			if tcp, ok := c.(*net.TCPConn); ok {
+				tcp.CloseWrite()
+			}
+		}
 	}
 }
 
-func sendStatus(ws *websocket.Conn, success bool, msg string) error {
-	log.Printf("Status: success=%v, msg=%s\n", success, msg)
-	data, err := encoding.Marshal(&wsproxy.Status{
-		Success: success,
+func resolve(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %s\n", err)
+		return
+	}
+	defer ws.Close()
+
+	result := new(wsproxy.Addresses)
+
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		log.Printf("Failed to read resolve message: %s\n", err)
+		return
+	}
+	req := new(wsproxy.Resolve)
+	err = encoding.Unmarshal(bytes.NewReader(msg), req)
+	if err != nil {
+		result.Error = fmt.Sprintf("Invalid resolve message: %s", err)
+	} else {
+		log.Printf("Resolve %s\n", req.Host)
+		result.Addrs, err = net.LookupHost(req.Host)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+		}
+	}
+	data, err := encoding.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to marshal addresses: %s\n", err)
+		return
+	}
+	err = ws.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		log.Printf("Failed to send addresses: %s\n", err)
+	}
+}
+
+// errorCode maps the dial error to the wsproxy error code.
+func errorCode(err error) wsproxy.ErrorCode {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return wsproxy.ErrorRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return wsproxy.ErrorNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return wsproxy.ErrorHostUnreachable
+	case errors.As(err, &dnsErr):
+		return wsproxy.ErrorNoHost
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return wsproxy.ErrorTimeout
+	default:
+		return wsproxy.ErrorUnknown
+	}
+}
+
+func sendStatus(ws *websocket.Conn, code wsproxy.ErrorCode, msg string) error {
+	log.Printf("Status: code=%s, msg=%s\n", code, msg)
+	return writeStatus(ws, &wsproxy.Status{
+		Success: code == wsproxy.ErrorNone,
 		Error:   msg,
+		Code:    code,
 	})
+}
+
+// sendConnected sends the success status with the features of the
+// connection.
+func sendConnected(ws *websocket.Conn, urgent, compress bool) error {
+	log.Printf("Status: connected, urgent=%v, compress=%v\n",
+		urgent, compress)
+	return writeStatus(ws, &wsproxy.Status{
+		Success:  true,
+		Code:     wsproxy.ErrorNone,
+		Urgent:   urgent,
+		Compress: compress,
+	})
+}
+
+func writeStatus(ws *websocket.Conn, status *wsproxy.Status) error {
+	data, err := encoding.Marshal(status)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,23 @@
+//
+// urgent_other.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build !darwin && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+const urgentSupported = false
+
+func writeUrgent(c net.Conn, data []byte) error {
+	return errors.New("urgent data not supported")
+}
@@ -0,0 +1,41 @@
+//
+// urgent_unix.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const urgentSupported = true
+
+// writeUrgent sends the data to the connection as TCP urgent data.
+func writeUrgent(c net.Conn, data []byte) error {
+	tcp, ok := c.(*net.TCPConn)
+	if !ok {
+		return fmt.Errorf("urgent data not supported for %T", c)
+	}
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Write(func(fd uintptr) bool {
+		serr = syscall.Sendto(int(fd), data, syscall.MSG_OOB, nil)
+		return serr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -11,6 +11,7 @@ package control
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 var (
@@ -22,6 +23,14 @@ var (
 	ShellPrompt string = "bbos \\W $ "
 )
 
+// BootTime is the time when the kernel was started.
+var BootTime = time.Now()
+
+// Uptime returns how long the kernel has been running.
+func Uptime() time.Duration {
+	return time.Since(BootTime)
+}
+
 type ValueType int
 
 const (
@@ -0,0 +1,24 @@
+//
+// control_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package control
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	start := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	elapsed := time.Since(start)
+
+	if uptime := Uptime(); uptime < elapsed {
+		t.Errorf("Uptime %v, expected at least %v", uptime, elapsed)
+	}
+}
@@ -15,4 +15,5 @@ var (
 	EINVAL = errors.New("EINVAL")
 	ENOSYS = errors.New("ENOSYS")
 	EBADF  = errors.New("EBADF")
+	EIO    = errors.New("EIO")
 )
@@ -0,0 +1,57 @@
+//
+// addr.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseAddr parses and validates the host:port address of the
+// network. The IPv6 addresses must be enclosed in brackets. The
+// returned host does not have the brackets. The port must be a
+// decimal port number.
+func ParseAddr(network, addr string) (host, port string, err error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		return "", "", net.UnknownNetworkError(network)
+	}
+	host, port, err = net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", err
+	}
+	if len(host) == 0 {
+		return "", "", &net.AddrError{
+			Err:  "missing host",
+			Addr: addr,
+		}
+	}
+	if strings.IndexByte(host, ':') >= 0 && net.ParseIP(host) == nil {
+		return "", "", &net.AddrError{
+			Err:  "invalid IPv6 address",
+			Addr: addr,
+		}
+	}
+	if len(port) == 0 {
+		return "", "", &net.AddrError{
+			Err:  "missing port",
+			Addr: addr,
+		}
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || n == 0 {
+		return "", "", &net.AddrError{
+			Err:  "invalid port",
+			Addr: addr,
+		}
+	}
+	return host, port, nil
+}
@@ -0,0 +1,65 @@
+//
+// addr_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"testing"
+)
+
+var parseAddrTests = []struct {
+	network string
+	addr    string
+	host    string
+	port    string
+	err     bool
+}{
+	{"tcp", "192.0.2.1:22", "192.0.2.1", "22", false},
+	{"tcp", "www.example.com:443", "www.example.com", "443", false},
+	{"tcp", "[2001:db8::1]:22", "2001:db8::1", "22", false},
+	{"tcp6", "[::1]:8100", "::1", "8100", false},
+	{"udp", "192.0.2.1:53", "192.0.2.1", "53", false},
+
+	// Missing port.
+	{"tcp", "192.0.2.1", "", "", true},
+	{"tcp", "192.0.2.1:", "", "", true},
+	{"tcp", "2001:db8::1", "", "", true},
+
+	// Invalid port.
+	{"tcp", "192.0.2.1:ssh", "", "", true},
+	{"tcp", "192.0.2.1:0", "", "", true},
+	{"tcp", "192.0.2.1:65536", "", "", true},
+	{"tcp", "192.0.2.1:-1", "", "", true},
+
+	// Invalid host.
+	{"tcp", ":22", "", "", true},
+	{"tcp", "[foo:bar]:22", "", "", true},
+
+	{"ip", "192.0.2.1:22", "", "", true},
+}
+
+func TestParseAddr(t *testing.T) {
+	for _, test := range parseAddrTests {
+		host, port, err := ParseAddr(test.network, test.addr)
+		if err != nil {
+			if !test.err {
+				t.Errorf("ParseAddr(%s, %s) failed: %s",
+					test.network, test.addr, err)
+			}
+			continue
+		}
+		if test.err {
+			t.Errorf("ParseAddr(%s, %s) succeeded", test.network, test.addr)
+			continue
+		}
+		if host != test.host || port != test.port {
+			t.Errorf("ParseAddr(%s, %s): got %s %s, expected %s %s",
+				test.network, test.addr, host, port, test.host, test.port)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+//
+// chain.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+var (
+	_ net.Conn = &tunnelConn{}
+)
+
+// DialChain dials the address addr through a chain of WebSocket
+// proxies. The first proxy is connected with a browser WebSocket and
+// each following proxy is reached by tunneling a WebSocket
+// connection through the previous one.
+func DialChain(proxies []string, network, addr string) (net.Conn, error) {
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("DialChain: no proxies")
+	}
+	if network != "tcp" {
+		return nil, fmt.Errorf("DialChain: unsupported network: %s", network)
+	}
+	if _, _, err := ParseAddr(network, addr); err != nil {
+		return nil, err
+	}
+
+	// The dial targets of the proxies: each proxy dials the next
+	// proxy and the last proxy dials the final address.
+	var targets []string
+	targets = append(targets, proxies[1:]...)
+	targets = append(targets, addr)
+
+	conn, err := DialTimeout(proxies[0], targets[0], DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(proxies); i++ {
+		next, err := tunnel(conn, proxies[i], targets[i], DefaultDialTimeout)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("DialChain: %s: %w", proxies[i], err)
+		}
+		conn = next
+	}
+	return conn, nil
+}
+
+// tunnel dials the address addr with the proxy that is reachable
+// over the connection conn. The timeout limits the WebSocket
+// handshake and the proxy's dial. The timeout 0 disables the limits.
+func tunnel(conn net.Conn, proxy, addr string, timeout time.Duration) (
+	net.Conn, error) {
+
+	u := &url.URL{
+		Scheme: "ws",
+		Host:   proxy,
+		Path:   "/proxy",
+	}
+	dialer := &websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			return conn, nil
+		},
+		HandshakeTimeout: timeout,
+	}
+	ws, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encoding.Marshal(&wsproxy.Dial{
+		Addr:    addr,
+		Timeout: timeout,
+	})
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	if timeout > 0 {
+		err = conn.SetDeadline(time.Now().Add(timeout))
+		if err != nil {
+			ws.Close()
+			return nil, err
+		}
+	}
+	err = ws.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	status := new(wsproxy.Status)
+	err = encoding.Unmarshal(bytes.NewReader(msg), status)
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	if !status.Success {
+		ws.Close()
+		return nil, &DialError{
+			Addr:    addr,
+			Message: status.Error,
+			Code:    status.Code,
+		}
+	}
+
+	return &tunnelConn{
+		ws:   ws,
+		addr: addr,
+	}, nil
+}
+
+// tunnelConn implements net.Conn over a tunneled WebSocket
+// connection.
+type tunnelConn struct {
+	ws   *websocket.Conn
+	addr string
+	data []byte
+}
+
+func (c *tunnelConn) Read(b []byte) (n int, err error) {
+	for len(c.data) == 0 {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		t, payload, err := wsproxy.ParseFrame(msg)
+		if err != nil {
+			return 0, err
+		}
+		switch t {
+		case wsproxy.FrameData:
+			c.data = payload
+		case wsproxy.FrameClose:
+			return 0, io.EOF
+		}
+	}
+	n = copy(b, c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func (c *tunnelConn) Write(b []byte) (n int, err error) {
+	err = c.ws.WriteMessage(websocket.BinaryMessage,
+		wsproxy.Frame(wsproxy.FrameData, b))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *tunnelConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *tunnelConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+func (c *tunnelConn) RemoteAddr() net.Addr {
+	return c
+}
+
+func (c *tunnelConn) Network() string {
+	return "tcp"
+}
+
+func (c *tunnelConn) String() string {
+	return c.addr
+}
+
+func (c *tunnelConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *tunnelConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *tunnelConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
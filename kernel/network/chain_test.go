@@ -0,0 +1,266 @@
+//
+// chain_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+// hijacker implements http.Hijacker for running the WebSocket
+// upgrade over a net.Conn.
+type hijacker struct {
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+	header http.Header
+}
+
+func (h *hijacker) Header() http.Header {
+	return h.header
+}
+
+func (h *hijacker) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (h *hijacker) WriteHeader(statusCode int) {
+}
+
+func (h *hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, h.rw, nil
+}
+
+// mockProxy implements a WebSocket proxy that accepts the dial
+// request and echoes all data it receives.
+func mockProxy(conn net.Conn, name string, dials chan<- string) error {
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return err
+	}
+	var upgrader websocket.Upgrader
+	ws, err := upgrader.Upgrade(&hijacker{
+		conn:   conn,
+		rw:     bufio.NewReadWriter(br, bufio.NewWriter(conn)),
+		header: make(http.Header),
+	}, req, nil)
+	if err != nil {
+		return err
+	}
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	dial := new(wsproxy.Dial)
+	err = encoding.Unmarshal(bytes.NewReader(msg), dial)
+	if err != nil {
+		return err
+	}
+	dials <- fmt.Sprintf("%s->%s", name, dial.Addr)
+
+	data, err := encoding.Marshal(&wsproxy.Status{
+		Success: true,
+	})
+	if err != nil {
+		return err
+	}
+	err = ws.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		return err
+	}
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		t, payload, err := wsproxy.ParseFrame(msg)
+		if err != nil {
+			return err
+		}
+		if t != wsproxy.FrameData {
+			continue
+		}
+		err = ws.WriteMessage(websocket.BinaryMessage,
+			wsproxy.Frame(wsproxy.FrameData, payload))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// silentProxy implements a WebSocket proxy that does not respond. If
+// upgrade is set, it completes the WebSocket handshake and reads the
+// dial request but never sends the dial status.
+func silentProxy(conn net.Conn, upgrade bool) error {
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return err
+	}
+	if !upgrade {
+		_, err = io.Copy(ioutil.Discard, br)
+		return err
+	}
+	var upgrader websocket.Upgrader
+	ws, err := upgrader.Upgrade(&hijacker{
+		conn:   conn,
+		rw:     bufio.NewReadWriter(br, bufio.NewWriter(conn)),
+		header: make(http.Header),
+	}, req, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+var tunnelTimeoutTests = []struct {
+	name    string
+	upgrade bool
+}{
+	{
+		name: "handshake",
+	},
+	{
+		name:    "status",
+		upgrade: true,
+	},
+}
+
+func TestTunnelTimeout(t *testing.T) {
+	for _, test := range tunnelTimeoutTests {
+		client, server := net.Pipe()
+		go silentProxy(server, test.upgrade)
+
+		start := time.Now()
+		_, err := tunnel(client, "proxyB:8100", "target:22",
+			50*time.Millisecond)
+		if err == nil {
+			t.Errorf("%s: tunnel succeeded", test.name)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: tunnel took %v", test.name, elapsed)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestDialChain(t *testing.T) {
+	dials := make(chan string, 2)
+
+	// The first proxy is the browser WebSocket. After the dial
+	// request, its data is tunneled to the second proxy.
+	client, server := net.Pipe()
+	go mockProxy(server, "proxyB", dials)
+
+	tunnelC := make(chan []byte, 1024)
+	go func() {
+		for data := range tunnelC {
+			client.Write(data)
+		}
+	}()
+
+	var dialed bool
+	fs := &fakeSocket{
+		hosts: map[string][]string{
+			"proxyB": {"192.0.2.2"},
+		},
+	}
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		if dialed {
+			_, payload, err := wsproxy.ParseFrame(data)
+			if err != nil {
+				t.Errorf("invalid frame: %s", err)
+				return
+			}
+			tunnelC <- payload
+			return
+		}
+		dialed = true
+		dial := new(wsproxy.Dial)
+		err := encoding.Unmarshal(bytes.NewReader(data), dial)
+		if err != nil {
+			t.Errorf("invalid dial request: %s", err)
+			return
+		}
+		dials <- fmt.Sprintf("proxyA->%s", dial.Addr)
+
+		status, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(status)
+
+		go func() {
+			var buf [4096]byte
+			for {
+				n, err := client.Read(buf[:])
+				if err != nil {
+					return
+				}
+				fs.message(wsproxy.Frame(wsproxy.FrameData, buf[:n]))
+			}
+		}()
+	}
+
+	conn, err := DialChain([]string{"proxyA:8100", "proxyB:8100"}, "tcp",
+		"target:22")
+	if err != nil {
+		t.Fatalf("DialChain failed: %s", err)
+	}
+	defer conn.Close()
+
+	var result []string
+	for i := 0; i < 2; i++ {
+		result = append(result, <-dials)
+	}
+	expected := []string{
+		"proxyA->192.0.2.2:8100",
+		"proxyB->target:22",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("dial requests: got %v, expected %v", result, expected)
+	}
+	if conn.RemoteAddr().String() != "target:22" {
+		t.Errorf("RemoteAddr: got %s, expected target:22", conn.RemoteAddr())
+	}
+
+	_, err = conn.Write([]byte("ping"))
+	if err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	var buf [4]byte
+	_, err = io.ReadFull(conn, buf[:])
+	if err != nil {
+		t.Fatalf("Read failed: %s", err)
+	}
+	if string(buf[:]) != "ping" {
+		t.Errorf("Read: got %q, expected %q", buf[:], "ping")
+	}
+}
@@ -0,0 +1,425 @@
+//
+// conformance_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+	"golang.org/x/crypto/ssh"
+)
+
+// peer is the remote end of a proxied connection. It reads the data
+// that the connection writes and writes data frames to the
+// connection. The peer implements net.Conn so that it can run
+// protocol servers.
+type peer struct {
+	conn   *WSConn
+	r      *io.PipeReader
+	wmutex sync.Mutex
+}
+
+// newPeer creates a connection and its remote peer.
+func newPeer(fs *fakeSocket) (*WSConn, *peer) {
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	r, w := io.Pipe()
+	p := &peer{
+		conn: conn,
+		r:    r,
+	}
+
+	// The frames are forwarded from a goroutine so that the
+	// connection's write loop does not block on the peer.
+	sendC := make(chan []byte, 1024)
+	fs.onSend = func(data []byte) {
+		sendC <- data
+	}
+	go func() {
+		for {
+			var data []byte
+			select {
+			case data = <-sendC:
+			case <-conn.Done():
+				// Closing the connection closes the WebSocket.
+				w.Close()
+				return
+			}
+			t, payload, err := wsproxy.ParseFrame(data)
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			switch t {
+			case wsproxy.FrameData:
+				w.Write(payload)
+			case wsproxy.FrameClose:
+				w.Close()
+			}
+		}
+	}()
+	return conn, p
+}
+
+func (p *peer) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// Write sends the data to the connection in frames of varying sizes.
+func (p *peer) Write(b []byte) (int, error) {
+	p.wmutex.Lock()
+	defer p.wmutex.Unlock()
+
+	for i, size := 0, 1; i < len(b); size = size*3 + 1 {
+		end := i + size
+		if end > len(b) {
+			end = len(b)
+		}
+		p.message(wsproxy.FrameData, b[i:end])
+		i = end
+	}
+	return len(b), nil
+}
+
+// CloseWrite sends the close frame to the connection.
+func (p *peer) CloseWrite() error {
+	p.wmutex.Lock()
+	defer p.wmutex.Unlock()
+
+	p.message(wsproxy.FrameClose, nil)
+	return nil
+}
+
+func (p *peer) Close() error {
+	p.r.Close()
+	return p.CloseWrite()
+}
+
+func (p *peer) message(t wsproxy.FrameType, payload []byte) {
+	select {
+	case p.conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(t, payload),
+	}:
+	case <-p.conn.Done():
+	}
+}
+
+func (p *peer) LocalAddr() net.Addr {
+	return p.conn.RemoteAddr()
+}
+
+func (p *peer) RemoteAddr() net.Addr {
+	return p.conn.LocalAddr()
+}
+
+func (p *peer) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (p *peer) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (p *peer) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func randomData(t *testing.T, n int) []byte {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestNetConnConformance tests that WSConn behaves as the net.Conn
+// that the protocol implementations, such as golang.org/x/crypto/ssh,
+// expect.
+func TestNetConnConformance(t *testing.T) {
+	t.Run("LargeRead", testLargeRead)
+	t.Run("LargeWrite", testLargeWrite)
+	t.Run("ConcurrentReadWrite", testConcurrentReadWrite)
+	t.Run("HalfClose", testHalfClose)
+	t.Run("EOF", testEOF)
+	t.Run("DeadlineRecovery", testDeadlineRecovery)
+	t.Run("SSH", testSSH)
+}
+
+// testLargeRead reads data that arrives in frames of varying sizes
+// with buffers that do not match the frame boundaries.
+func testLargeRead(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	data := randomData(t, 1024*1024)
+	go func() {
+		peer.Write(data)
+		peer.CloseWrite()
+	}()
+
+	var result []byte
+	buf := make([]byte, 1000)
+	for {
+		n, err := conn.Read(buf)
+		result = append(result, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("Read: got %d bytes, expected %d",
+			len(result), len(data))
+	}
+}
+
+// testLargeWrite writes data with one Write call.
+func testLargeWrite(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	data := randomData(t, 1024*1024)
+	n, err := conn.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write: got %d, %v", n, err)
+	}
+	conn.CloseWrite()
+
+	result, err := ioutil.ReadAll(peer)
+	if err != nil {
+		t.Fatalf("peer read failed: %s", err)
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("peer read %d bytes, expected %d",
+			len(result), len(data))
+	}
+}
+
+// testConcurrentReadWrite writes and reads the connection from
+// separate goroutines while the peer echoes the data back.
+func testConcurrentReadWrite(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	go func() {
+		io.Copy(peer, peer)
+		peer.CloseWrite()
+	}()
+
+	data := randomData(t, 256*1024)
+	writeErrC := make(chan error)
+	go func() {
+		for i := 0; i < len(data); i += 4096 {
+			if _, err := conn.Write(data[i : i+4096]); err != nil {
+				writeErrC <- err
+				return
+			}
+		}
+		writeErrC <- conn.CloseWrite()
+	}()
+
+	result, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("Read failed: %s", err)
+	}
+	if err := <-writeErrC; err != nil {
+		t.Errorf("Write failed: %s", err)
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("echo: got %d bytes, expected %d",
+			len(result), len(data))
+	}
+}
+
+// testHalfClose sends a request, shuts down the write half, and reads
+// the response that the peer sends after the end of the request.
+func testHalfClose(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	go func() {
+		request, _ := ioutil.ReadAll(peer)
+		peer.Write(append([]byte("response to "), request...))
+		peer.CloseWrite()
+	}()
+
+	if _, err := conn.Write([]byte("request")); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite failed: %s", err)
+	}
+	if _, err := conn.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write after CloseWrite: got %v, expected %v",
+			err, net.ErrClosed)
+	}
+	response, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Read failed: %s", err)
+	}
+	if string(response) != "response to request" {
+		t.Errorf("Read: got %q", response)
+	}
+}
+
+// testEOF tests that the EOF is sticky and that the connection can
+// still be written and closed after the peer has closed its side.
+func testEOF(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+
+	go func() {
+		peer.Write([]byte("bye"))
+		peer.CloseWrite()
+	}()
+
+	data, err := ioutil.ReadAll(conn)
+	if err != nil || string(data) != "bye" {
+		t.Fatalf("Read: got %q, %v", data, err)
+	}
+	var buf [16]byte
+	for i := 0; i < 3; i++ {
+		if n, err := conn.Read(buf[:]); n != 0 || err != io.EOF {
+			t.Errorf("Read after EOF: got %d, %v", n, err)
+		}
+	}
+	if _, err := conn.Write([]byte("late")); err != nil {
+		t.Errorf("Write after peer close: %s", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close failed: %s", err)
+	}
+	if _, err := conn.Read(buf[:]); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read after Close: got %v, expected %v",
+			err, net.ErrClosed)
+	}
+}
+
+// testDeadlineRecovery tests that the connection works normally after
+// a read timeout and that the data arriving after the timeout is not
+// lost.
+func testDeadlineRecovery(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	var buf [16]byte
+	conn.SetDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err := conn.Read(buf[:])
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read: got %v, expected %v",
+			err, os.ErrDeadlineExceeded)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Read error %v is not a timeout", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	go peer.Write([]byte("data"))
+	n, err := io.ReadFull(conn, buf[:4])
+	if err != nil || string(buf[:n]) != "data" {
+		t.Errorf("Read after timeout: got %q, %v", buf[:n], err)
+	}
+}
+
+// testSSH runs the SSH handshake under a deadline, as ssh.Dial does,
+// and sends a keepalive request over the connection.
+func testSSH(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn, peer := newPeer(fs)
+	defer conn.Close()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		NoClientAuth: true,
+	}
+	serverConfig.AddHostKey(signer)
+
+	serverErrC := make(chan error, 1)
+	go func() {
+		sc, chans, reqs, err := ssh.NewServerConn(peer, serverConfig)
+		if err != nil {
+			serverErrC <- err
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			for ch := range chans {
+				ch.Reject(ssh.Prohibited, "no channels")
+			}
+		}()
+		serverErrC <- sc.Wait()
+	}()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c, chans, reqs, err := ssh.NewClientConn(conn, conn.String(),
+		&ssh.ClientConfig{
+			User:            "test",
+			HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+		})
+	if err != nil {
+		t.Fatalf("SSH handshake failed: %s", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(c, chans, reqs)
+	ok, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	if err != nil {
+		t.Errorf("keepalive failed: %s", err)
+	}
+	if ok {
+		t.Errorf("keepalive: unexpected success")
+	}
+	if _, err := client.NewSession(); err == nil {
+		t.Errorf("NewSession succeeded")
+	}
+	client.Close()
+
+	select {
+	case err := <-serverErrC:
+		if err != nil && err != io.EOF {
+			t.Errorf("server: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("server did not see the connection close")
+	}
+}
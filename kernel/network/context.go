@@ -0,0 +1,26 @@
+//
+// context.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"context"
+)
+
+// BindContext binds the connection's lifetime to the context. The
+// connection is closed when the context is canceled. The binding
+// ends without side effects if the connection is closed first.
+func BindContext(ctx context.Context, conn *WSConn) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-conn.Done():
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+//
+// context_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBindContextCancel(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	BindContext(ctx, conn)
+	cancel()
+
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection not closed on cancel")
+	}
+	var buf [1]byte
+	_, err := conn.Read(buf[:])
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read: got %v, expected %v", err, net.ErrClosed)
+	}
+}
+
+func TestBindContextClose(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+	BindContext(ctx, conn)
+	if runtime.NumGoroutine() != before+1 {
+		t.Fatalf("BindContext did not start a goroutine")
+	}
+	conn.Close()
+
+	timeout := time.After(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		select {
+		case <-timeout:
+			t.Fatalf("BindContext goroutine did not exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		t.Errorf("context canceled: %s", err)
+	}
+}
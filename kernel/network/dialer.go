@@ -0,0 +1,160 @@
+//
+// dialer.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+// DefaultDialTimeout specifies the dial timeout for the dial
+// functions that do not take an explicit timeout argument.
+var DefaultDialTimeout = 30 * time.Second
+
+// ErrOffline is returned when dialing while the browser is offline.
+var ErrOffline = errors.New("network is offline")
+
+// Dialer contains options for connecting to addresses through a
+// WebSocket proxy.
+type Dialer struct {
+	// Proxy specifies the address of the WebSocket proxy.
+	Proxy string
+
+	// Timeout specifies the timeout of each connection attempt. If
+	// zero, DefaultDialTimeout is used.
+	Timeout time.Duration
+
+	// Protocols specifies the WebSocket subprotocols requested in
+	// the opening handshake.
+	Protocols []string
+
+	// Header specifies additional headers for the opening
+	// handshake. Note that browsers do not allow setting the
+	// handshake headers and the headers are sent only by WebSocket
+	// implementations that support them.
+	Header http.Header
+
+	// Compress requests the compression of the connection data. The
+	// data is compressed if the proxy supports it.
+	Compress bool
+
+	// KeepAlive specifies the interval of the keepalive frames that
+	// the connection sends to the proxy. If zero, no keepalive
+	// frames are sent.
+	KeepAlive time.Duration
+
+	// ReadChunk limits the number of bytes a single Read of the
+	// connection returns, as set with WSConn.SetReadChunk. If zero,
+	// the reads are not limited.
+	ReadChunk int
+
+	// Rewrite, if set, is called with the network and address of
+	// each dial before the address is resolved and sent to the
+	// proxy. It returns the network and address to connect to or an
+	// error that blocks the connection. The error is returned from
+	// the dial as-is.
+	Rewrite func(network, addr string) (string, string, error)
+}
+
+// Dial connects to the address addr through the WebSocket proxy like
+// DialContext with the background context.
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address addr through the WebSocket
+// proxy. The network must be tcp or udp. The udp connections are
+// returned as *UDPConn. If the host part of the address is a host
+// name, it is resolved with the proxy and the resolved addresses are
+// tried in order until one of them connects. The address is resolved
+// after the dialer's Rewrite function. Each connection attempt
+// is limited by the dialer's Timeout and the whole dial by the
+// context; whichever expires first aborts the attempt. The context
+// does not affect the established connection.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (
+	net.Conn, error) {
+
+	if d.Rewrite != nil {
+		var err error
+		network, addr, err = d.Rewrite(network, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("Dial: unsupported network: %s", network)
+	}
+	host, port, err := ParseAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.Online() {
+		return nil, ErrOffline
+	}
+	if net.ParseIP(host) != nil {
+		return d.dial(ctx, network, net.JoinHostPort(host, port))
+	}
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var conn net.Conn
+		conn, err = d.dial(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// timeout returns the timeout of a connection attempt. The timeout
+// is capped by the context's deadline.
+func (d *Dialer) timeout(ctx context.Context) time.Duration {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	deadline, ok := ctx.Deadline()
+	if ok {
+		remaining := time.Until(deadline)
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
+}
+
+func (d *Dialer) newWebSocket(path string) *WebSocket {
+	return NewWebSocket(fmt.Sprintf("ws://%s%s", d.Proxy, path),
+		d.Protocols, d.Header)
+}
+
+// DialError describes a dial that the proxy failed to connect.
+type DialError struct {
+	Addr    string
+	Message string
+	Code    wsproxy.ErrorCode
+}
+
+func (e *DialError) Error() string {
+	if len(e.Message) > 0 {
+		return e.Message
+	}
+	return e.Code.String()
+}
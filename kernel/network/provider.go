@@ -0,0 +1,162 @@
+//
+// provider.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"syscall/js"
+)
+
+// Provider implements WebSocket connections for the network
+// stack. The default provider uses the JavaScript WebSocket glue
+// functions of the browser environment (wasm/net.js).
+type Provider interface {
+	// Open opens a WebSocket connection to the URL. The
+	// connection events are reported with the callbacks.
+	Open(url string, protocols []string, header http.Header,
+		cb Callbacks) Socket
+	// Online reports whether the browser has network access.
+	Online() bool
+}
+
+// Callbacks define the WebSocket event handlers. The OnMessage
+// receives binary messages and OnText text messages.
+type Callbacks struct {
+	OnOpen    func()
+	OnMessage func(data []byte)
+	OnText    func(text string)
+	OnError   func(err error)
+	OnClose   func()
+}
+
+// Socket implements an open WebSocket connection.
+type Socket interface {
+	// Send sends the data as a binary message.
+	Send(data []byte)
+	// BufferedAmount returns the number of bytes queued but not
+	// yet transmitted to the network.
+	BufferedAmount() int
+	// Close closes the connection. No callbacks are called after
+	// Close returns.
+	Close()
+}
+
+var provider Provider = new(jsProvider)
+
+// SetProvider sets the WebSocket provider and returns the previous
+// provider. The nil provider restores the default JavaScript
+// provider.
+func SetProvider(p Provider) Provider {
+	old := provider
+	if p == nil {
+		p = new(jsProvider)
+	}
+	provider = p
+	return old
+}
+
+type jsProvider struct{}
+
+func (p *jsProvider) Open(url string, protocols []string,
+	header http.Header, cb Callbacks) Socket {
+
+	s := new(jsSocket)
+	s.onOpen = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cb.OnOpen()
+		return nil
+	})
+	s.onMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			log.Printf("Invalid onMessage data\n")
+			return nil
+		}
+		data := args[0]
+		if data.Type() == js.TypeString {
+			cb.OnText(data.String())
+			return nil
+		}
+
+		len := data.Length()
+		bytes := make([]byte, len)
+		for i := 0; i < len; i++ {
+			v := data.Index(i).Int()
+			bytes[i] = byte(v)
+		}
+		cb.OnMessage(bytes)
+		return nil
+	})
+	s.onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cb.OnError(errors.New(args[0].String()))
+		return nil
+	})
+	s.onClose = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cb.OnClose()
+		return nil
+	})
+
+	jsProtocols := make([]interface{}, len(protocols))
+	for idx, protocol := range protocols {
+		jsProtocols[idx] = protocol
+	}
+	jsHeader := make(map[string]interface{})
+	for key, values := range header {
+		jsHeader[key] = strings.Join(values, ", ")
+	}
+
+	s.native = js.Global().Call("webSocketNew", url, jsProtocols, jsHeader,
+		s.onOpen, s.onMessage, s.onError, s.onClose)
+
+	return s
+}
+
+// Online returns the navigator.onLine status. The browser is assumed
+// to be online if the status is not available.
+func (p *jsProvider) Online() bool {
+	navigator := js.Global().Get("navigator")
+	if navigator.Type() != js.TypeObject {
+		return true
+	}
+	online := navigator.Get("onLine")
+	if online.Type() != js.TypeBoolean {
+		return true
+	}
+	return online.Bool()
+}
+
+type jsSocket struct {
+	native    js.Value
+	onOpen    js.Func
+	onMessage js.Func
+	onError   js.Func
+	onClose   js.Func
+}
+
+func (s *jsSocket) Send(data []byte) {
+	buf := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(buf, data)
+	js.Global().Call("webSocketSend", s.native, buf)
+}
+
+func (s *jsSocket) BufferedAmount() int {
+	return js.Global().Call("webSocketBufferedAmount", s.native).Int()
+}
+
+func (s *jsSocket) Close() {
+	js.Global().Call("webSocketClose", s.native)
+
+	// The glue function removed the event handlers so the callback
+	// functions can be released.
+	s.onOpen.Release()
+	s.onMessage.Release()
+	s.onError.Release()
+	s.onClose.Release()
+}
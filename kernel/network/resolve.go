@@ -0,0 +1,83 @@
+//
+// resolve.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+// Resolve resolves the host name with the WebSocket proxy. It returns
+// the host's addresses in the order the proxy's resolver returned
+// them.
+func Resolve(proxy, host string) ([]string, error) {
+	d := &Dialer{
+		Proxy: proxy,
+	}
+	return d.Resolve(host)
+}
+
+// Resolve resolves the host name with the dialer's WebSocket proxy.
+func (d *Dialer) Resolve(host string) ([]string, error) {
+	return d.resolve(context.Background(), host)
+}
+
+func (d *Dialer) resolve(ctx context.Context, host string) (
+	[]string, error) {
+
+	ws := d.newWebSocket("/resolve")
+	defer ws.Close()
+
+	for {
+		var msg Message
+		select {
+		case msg = <-ws.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		switch msg.Type {
+		case Open:
+			data, err := encoding.Marshal(&wsproxy.Resolve{
+				Host: host,
+			})
+			if err != nil {
+				return nil, err
+			}
+			ws.Send(data)
+
+		case Error:
+			return nil, msg.Error
+
+		case Close:
+			return nil, fmt.Errorf("Connection closed")
+
+		case Data:
+			if msg.Text {
+				return nil, ErrTextMessage
+			}
+			result := new(wsproxy.Addresses)
+			err := encoding.Unmarshal(bytes.NewReader(msg.Data), result)
+			if err != nil {
+				return nil, err
+			}
+			if !result.Success {
+				return nil, errors.New(result.Error)
+			}
+			if len(result.Addrs) == 0 {
+				return nil, fmt.Errorf("no addresses for host %s", host)
+			}
+			return result.Addrs, nil
+		}
+	}
+}
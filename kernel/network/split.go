@@ -0,0 +1,47 @@
+//
+// split.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"io"
+)
+
+// SplitConn splits the connection into its read and write halves so
+// that they can be passed to different goroutines. The halves share
+// the connection. Closing the read half shuts down the connection's
+// read half with CloseRead and closing the write half shuts down its
+// write half with CloseWrite. The connection is closed when both
+// halves are closed.
+func SplitConn(c *WSConn) (r io.ReadCloser, w io.WriteCloser) {
+	return &readHalf{c: c}, &writeHalf{c: c}
+}
+
+type readHalf struct {
+	c *WSConn
+}
+
+func (h *readHalf) Read(b []byte) (int, error) {
+	return h.c.Read(b)
+}
+
+func (h *readHalf) Close() error {
+	return h.c.CloseRead()
+}
+
+type writeHalf struct {
+	c *WSConn
+}
+
+func (h *writeHalf) Write(b []byte) (int, error) {
+	return h.c.Write(b)
+}
+
+func (h *writeHalf) Close() error {
+	return h.c.CloseWrite()
+}
@@ -0,0 +1,128 @@
+//
+// split_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+func TestSplitConn(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	r, w := SplitConn(conn)
+
+	writeDone := make(chan error)
+	go func() {
+		_, err := io.Copy(w, strings.NewReader("request"))
+		if err == nil {
+			err = w.Close()
+		}
+		writeDone <- err
+	}()
+
+	readDone := make(chan []byte)
+	go func() {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("read half: %v", err)
+		}
+		readDone <- data
+	}()
+
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("response")),
+	}
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameClose, nil),
+	}
+
+	if err := <-writeDone; err != nil {
+		t.Errorf("write half: %v", err)
+	}
+	if data := <-readDone; string(data) != "response" {
+		t.Errorf("read half: got %q, expected %q", data, "response")
+	}
+	if _, err := w.Write([]byte("more")); err != net.ErrClosed {
+		t.Errorf("Write after close: got %v, expected %v",
+			err, net.ErrClosed)
+	}
+
+	// The connection stays open until the read half is closed.
+	select {
+	case <-conn.Done():
+		t.Errorf("connection closed with the read half open")
+	default:
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("read half Close: %v", err)
+	}
+	<-conn.Done()
+
+	var expected []byte
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("request"))...)
+	expected = append(expected, wsproxy.Frame(wsproxy.FrameClose, nil)...)
+	if !bytes.Equal(fs.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, expected)
+	}
+}
+
+func TestSplitConnCloseRead(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	r, w := SplitConn(conn)
+
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("discarded")),
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("read half Close: %v", err)
+	}
+	if err := r.Close(); err != net.ErrClosed {
+		t.Errorf("second Close: got %v, expected %v",
+			err, net.ErrClosed)
+	}
+	var buf [16]byte
+	if n, err := r.Read(buf[:]); n != 0 || err != io.EOF {
+		t.Errorf("Read after close: got %d, %v", n, err)
+	}
+
+	// The write half keeps working.
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("write half Close: %v", err)
+	}
+	<-conn.Done()
+
+	var expected []byte
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("data"))...)
+	expected = append(expected, wsproxy.Frame(wsproxy.FrameClose, nil)...)
+	if !bytes.Equal(fs.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, expected)
+	}
+}
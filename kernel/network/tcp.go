@@ -10,78 +10,194 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"net/http"
+	"os"
 	"sync"
-	"syscall/js"
 	"time"
 
 	"github.com/markkurossi/blackbox-os/lib/encoding"
 	"github.com/markkurossi/blackbox-os/lib/wsproxy"
 )
 
-var (
-	wsNew   = js.Global().Get("webSocketNew")
-	wsSend  = js.Global().Get("webSocketSend")
-	wsClose = js.Global().Get("webSocketClose")
-)
-
+// ErrTextMessage is returned when a binary-only connection receives
+// a WebSocket text message.
+var ErrTextMessage = errors.New("unexpected WebSocket text message")
+
+// ErrUnsupported is returned for operations that the proxy does not
+// support.
+var ErrUnsupported = errors.New("operation not supported")
+
+// DialTimeout connects to the address addr through the WebSocket
+// proxy. If the host part of the address is a host name, it is
+// resolved with the proxy and the resolved addresses are tried in
+// order until one of them connects. The timeout applies to the whole
+// dial, including the name resolution. If the timeout is zero or
+// negative, the dial has no deadline and only the connection
+// attempts time out after DefaultDialTimeout.
 func DialTimeout(proxy, addr string, timeout time.Duration) (net.Conn, error) {
-	url := fmt.Sprintf("ws://%s/proxy", proxy)
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return DialContext(ctx, proxy, addr)
+}
+
+// timeoutContext returns a context that is done after the timeout.
+// The context has no deadline if the timeout is zero or negative.
+func timeoutContext(timeout time.Duration) (
+	context.Context, context.CancelFunc) {
+
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// DialContext connects to the address addr through the WebSocket
+// proxy like DialTimeout. If the context is done before the
+// connection is established, the dial is aborted, the WebSocket is
+// closed, and the context's error is returned. The context does not
+// affect the established connection.
+func DialContext(ctx context.Context, proxy, addr string) (net.Conn, error) {
+	d := &Dialer{
+		Proxy: proxy,
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// DialUDP connects to the UDP address addr through the WebSocket
+// proxy. The datagram boundaries are preserved: each Write sends one
+// datagram and each Read returns one datagram. The timeout applies to
+// the whole dial, including the name resolution, like in DialTimeout.
+func DialUDP(proxy, addr string, timeout time.Duration) (*UDPConn, error) {
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
 
-	conn := NewWSConn(NewWebSocket(url), "tcp", addr)
+	d := &Dialer{
+		Proxy: proxy,
+	}
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, ok := conn.(*UDPConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("DialUDP: %s is not a UDP connection",
+			conn.RemoteAddr())
+	}
+	return udpConn, nil
+}
+
+// DialRaw connects to the address addr like DialTimeout and returns
+// the connection together with the WebSocket that carries it. The
+// WebSocket lets callers send their own messages on the established
+// connection. Note that the connection's message loop consumes the
+// messages received from the WebSocket, that the messages sent must
+// be valid wsproxy frames since the proxy terminates the connection
+// on invalid frames, and that the raw messages are not ordered with
+// the data queued with WSConn.Write.
+func DialRaw(proxy, addr string, timeout time.Duration) (
+	*WSConn, *WebSocket, error) {
+
+	conn, err := DialTimeout(proxy, addr, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	wsConn := conn.(*WSConn)
+	return wsConn, wsConn.ws, nil
+}
+
+func (d *Dialer) dial(ctx context.Context, network, addr string) (
+	net.Conn, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout(ctx))
+	defer cancel()
+
+	conn := NewWSConn(d.newWebSocket("/proxy"), network, addr)
+	conn.packet = network == "udp"
 
 	// Wait for WebSocket to connect.
-	for msg := range conn.ws.C {
+	for {
+		var msg Message
+		select {
+		case msg = <-conn.ws.C:
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		}
 		switch msg.Type {
 		case Open:
 			// Dial.
 			req := wsproxy.Dial{
-				Addr:    addr,
-				Timeout: timeout,
+				Addr:     addr,
+				Timeout:  d.timeout(ctx),
+				Network:  network,
+				Compress: d.Compress,
 			}
 			data, err := encoding.Marshal(&req)
 			if err != nil {
 				conn.Close()
 				return nil, err
 			}
-			conn.Write(data)
+			// The dial request is sent without framing.
+			conn.ws.Send(data)
 
 		case Error:
 			conn.Close()
+			if !provider.Online() {
+				return nil, fmt.Errorf("%w: %s", ErrOffline, msg.Error)
+			}
 			return nil, msg.Error
 
 		case Close:
+			conn.Close()
+			if !provider.Online() {
+				return nil, ErrOffline
+			}
 			return nil, fmt.Errorf("Connection closed")
 
 		case Data:
+			if msg.Text {
+				conn.Close()
+				return nil, ErrTextMessage
+			}
 			status := new(wsproxy.Status)
 			err := encoding.Unmarshal(bytes.NewReader(msg.Data), status)
 			if err != nil {
+				conn.Close()
 				return nil, err
 			}
 			if !status.Success {
 				conn.Close()
-				return nil, errors.New(status.Error)
+				return nil, &DialError{
+					Addr:    addr,
+					Message: status.Error,
+					Code:    status.Code,
+				}
+			}
+			conn.urgent = status.Urgent
+			conn.compress = d.Compress && status.Compress
+			conn.SetReadChunk(d.ReadChunk)
+			if d.KeepAlive > 0 {
+				go conn.keepAlive(d.KeepAlive)
 			}
 			go conn.messageLoop()
+			if conn.packet {
+				return &UDPConn{conn}, nil
+			}
 			return conn, nil
 		}
 	}
-	return nil, fmt.Errorf("Connection timeout")
 }
 
 type WebSocket struct {
-	URL       string
-	Native    js.Value
-	C         chan Message
-	onOpen    js.Func
-	onMessage js.Func
-	onError   js.Func
-	onClose   js.Func
+	URL    string
+	Socket Socket
+	C      chan Message
 }
 
 func (ws *WebSocket) Network() string {
@@ -93,13 +209,17 @@ func (ws *WebSocket) String() string {
 }
 
 func (ws *WebSocket) Send(data []byte) {
-	buf := js.Global().Get("Uint8Array").New(len(data))
-	js.CopyBytesToJS(buf, data)
-	wsSend.Invoke(ws.Native, buf)
+	ws.Socket.Send(data)
+}
+
+// BufferedAmount returns the number of bytes queued in the browser
+// WebSocket but not yet transmitted to the network.
+func (ws *WebSocket) BufferedAmount() int {
+	return ws.Socket.BufferedAmount()
 }
 
 func (ws *WebSocket) Close() {
-	wsClose.Invoke(ws.Native)
+	ws.Socket.Close()
 
 	// Drain message channel
 loop:
@@ -121,10 +241,14 @@ const (
 	Data
 )
 
+// Message defines a WebSocket event. For the Data messages, the Text
+// specifies if the data was received as a text message. The text
+// messages are UTF-8 encoded.
 type Message struct {
 	Type  MessageType
 	Error error
 	Data  []byte
+	Text  bool
 }
 
 func (m *Message) String() string {
@@ -139,6 +263,9 @@ func (m *Message) String() string {
 		return "Close"
 
 	case Data:
+		if m.Text {
+			return fmt.Sprintf("Text=%q", m.Data)
+		}
 		return fmt.Sprintf("Data=%x", m.Data)
 
 	default:
@@ -146,65 +273,86 @@ func (m *Message) String() string {
 	}
 }
 
-func NewWebSocket(url string) *WebSocket {
+// NewWebSocket creates a new WebSocket connection to the URL. The
+// optional protocols and header specify the WebSocket subprotocols
+// and the additional headers of the opening handshake.
+func NewWebSocket(url string, protocols []string,
+	header http.Header) *WebSocket {
+
 	ws := &WebSocket{
 		URL: url,
 		C:   make(chan Message),
 	}
-	ws.onOpen = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		ws.C <- Message{
-			Type: Open,
-		}
-		return nil
-	})
-	ws.onMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		if len(args) != 1 {
-			log.Printf("Invalid onMessage data\n")
-			return nil
-		}
-		data := args[0]
-
-		len := data.Length()
-		bytes := make([]byte, len)
-		for i := 0; i < len; i++ {
-			v := data.Index(i).Int()
-			bytes[i] = byte(v)
-		}
-
-		ws.C <- Message{
-			Type: Data,
-			Data: bytes,
-		}
-		return nil
-	})
-	ws.onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		ws.C <- Message{
-			Type:  Error,
-			Error: errors.New(args[0].String()),
-		}
-		return nil
-	})
-	ws.onClose = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		ws.C <- Message{
-			Type: Close,
-		}
-		return nil
+	ws.Socket = provider.Open(url, protocols, header, Callbacks{
+		OnOpen: func() {
+			ws.C <- Message{
+				Type: Open,
+			}
+		},
+		OnMessage: func(data []byte) {
+			ws.C <- Message{
+				Type: Data,
+				Data: data,
+			}
+		},
+		OnText: func(text string) {
+			ws.C <- Message{
+				Type: Data,
+				Data: []byte(text),
+				Text: true,
+			}
+		},
+		OnError: func(err error) {
+			ws.C <- Message{
+				Type:  Error,
+				Error: err,
+			}
+		},
+		OnClose: func() {
+			ws.C <- Message{
+				Type: Close,
+			}
+		},
 	})
 
-	ws.Native = wsNew.Invoke(url, ws.onOpen, ws.onMessage, ws.onError,
-		ws.onClose)
-
 	return ws
 }
 
+// WSConn implements net.Conn over a WebSocket proxy connection. The
+// connection supports one reader and one writer goroutine running
+// concurrently. Close can be called from any goroutine: it unblocks
+// pending Read calls, flushes the queued outbound data, and makes all
+// subsequent Read, Write, and Close calls fail with net.ErrClosed.
+// CloseRead and CloseWrite shut down the read and write halves of the
+// connection, and the connection is closed when both halves are shut
+// down. In the packet mode, the connection preserves the message
+// boundaries of the data frames.
 type WSConn struct {
-	mutex   sync.Mutex
-	cond    *sync.Cond
-	ws      *WebSocket
-	network string
-	addr    string
-	data    []byte
-	err     error
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	ws        *WebSocket
+	network   string
+	addr      string
+	data      []byte
+	packet    bool
+	packets   [][]byte
+	wpackets  [][]byte
+	err       error
+	rdeadline time.Time
+	rtimer    *time.Timer
+	rchunk    int
+	wdeadline time.Time
+	wdata     []byte
+	udata     []byte
+	urgent    bool
+	compress  bool
+	rclosed   bool
+	wclosed   bool
+	wfin      bool
+	closed    bool
+	wdone     bool
+	keepalive bool
+	done      chan struct{}
 }
 
 func NewWSConn(ws *WebSocket, network, addr string) *WSConn {
@@ -212,68 +360,335 @@ func NewWSConn(ws *WebSocket, network, addr string) *WSConn {
 		ws:      ws,
 		network: network,
 		addr:    addr,
+		done:    make(chan struct{}),
 	}
 	conn.cond = sync.NewCond(&conn.mutex)
+	go conn.writeLoop()
 	return conn
 }
 
-func (c *WSConn) messageLoop() {
-	for msg := range c.ws.C {
-		c.cond.L.Lock()
+// writeLoop sends the queued outbound data to the WebSocket. The
+// close frame of the shut down write half is sent after the pending
+// data. The loop terminates when the connection is closed and all
+// pending data has been sent.
+func (c *WSConn) writeLoop() {
+	c.cond.L.Lock()
+	for {
+		for len(c.wdata) == 0 && len(c.udata) == 0 &&
+			len(c.wpackets) == 0 && !c.keepalive && !c.closed &&
+			(!c.wclosed || c.wfin) {
+			c.cond.Wait()
+		}
+		var frame []byte
+		if len(c.udata) > 0 {
+			// The urgent data is sent ahead of the queued data.
+			frame = wsproxy.Frame(wsproxy.FrameUrgent, c.udata)
+			c.udata = nil
+		} else if len(c.wpackets) > 0 {
+			frame = wsproxy.DataFrame(c.wpackets[0], c.compress)
+			c.wpackets = c.wpackets[1:]
+		} else if len(c.wdata) > 0 {
+			frame = wsproxy.DataFrame(c.wdata, c.compress)
+			c.wdata = nil
+		} else if c.keepalive && !c.closed {
+			frame = wsproxy.Frame(wsproxy.FrameKeepalive, nil)
+			c.keepalive = false
+		} else if c.wclosed && !c.wfin {
+			frame = wsproxy.Frame(wsproxy.FrameClose, nil)
+			c.wfin = true
+		} else {
+			break
+		}
+		c.cond.L.Unlock()
 
-		switch msg.Type {
-		case Data:
-			// XXX need a flow control here, if buffer too big, close
-			// connection.
-			c.data = append(c.data, msg.Data...)
+		c.ws.Send(frame)
 
-		case Error:
-			c.err = msg.Error
+		c.cond.L.Lock()
+	}
+	c.wdone = true
+	c.cond.Broadcast()
+	c.cond.L.Unlock()
+}
 
-		case Open:
-			c.err = fmt.Errorf("unexpected WebSocket open message")
+// keepAlive queues a keepalive frame to be sent at each interval
+// until the connection is closed.
+func (c *WSConn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		case Close:
-			c.err = io.EOF
+	for {
+		select {
+		case <-ticker.C:
+			c.cond.L.Lock()
+			c.keepalive = true
+			c.cond.Broadcast()
+			c.cond.L.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// messageLoop processes the WebSocket messages. The first error
+// terminates the connection but the loop keeps consuming messages
+// until the WebSocket or the connection is closed so that the
+// WebSocket callbacks never block. The data already received stays
+// in the connection's input buffer when the loop terminates.
+func (c *WSConn) messageLoop() {
+	for {
+		var msg Message
+		select {
+		case msg = <-c.ws.C:
+		case <-c.done:
+			// Close drains the pending messages.
+			return
+		}
+		c.cond.L.Lock()
+		if c.err == nil {
+			switch msg.Type {
+			case Data:
+				if msg.Text {
+					// The proxy protocol is binary.
+					c.err = ErrTextMessage
+				} else {
+					c.frame(msg.Data)
+				}
+
+			case Error:
+				c.err = msg.Error
+
+			case Open:
+				c.err = fmt.Errorf("unexpected WebSocket open message")
+
+			case Close:
+				c.err = io.EOF
+			}
+			c.cond.Broadcast()
 		}
-		c.cond.Signal()
 		c.cond.L.Unlock()
-		if c.err != nil {
-			break
+
+		if msg.Type == Close {
+			return
 		}
 	}
 }
 
+// frame processes the proxy frame. Only the payloads of the data
+// frames are appended to the connection's input buffer. The control
+// frames are consumed here.
+func (c *WSConn) frame(msg []byte) {
+	t, payload, err := wsproxy.ParseFrame(msg)
+	if err != nil {
+		c.err = err
+		return
+	}
+	switch t {
+	case wsproxy.FrameDeflate:
+		if !c.compress {
+			c.err = fmt.Errorf("unexpected compressed frame")
+			return
+		}
+		payload, err = wsproxy.Inflate(payload)
+		if err != nil {
+			c.err = err
+			return
+		}
+		fallthrough
+
+	case wsproxy.FrameData:
+		// XXX need a flow control here, if buffer too big, close
+		// connection.
+		switch {
+		case c.rclosed:
+			// The read half is shut down and the data is discarded.
+		case c.packet:
+			c.packets = append(c.packets,
+				append([]byte(nil), payload...))
+		default:
+			c.data = append(c.data, payload...)
+		}
+
+	case wsproxy.FrameKeepalive, wsproxy.FrameFlow:
+
+	case wsproxy.FrameClose:
+		c.err = io.EOF
+	}
+}
+
 func (c *WSConn) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
 	c.cond.L.Lock()
-	for len(c.data) == 0 && c.err == nil {
+	defer c.cond.L.Unlock()
+
+	for len(c.data) == 0 && len(c.packets) == 0 && c.err == nil &&
+		!c.closed && !c.rclosed && !expired(c.rdeadline) {
+
 		// XXX need a flow control, if buffer empty, request data with
 		// ws.Read().
 		c.cond.Wait()
 	}
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.rclosed {
+		return 0, io.EOF
+	}
+	if expired(c.rdeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
 
-	n = copy(b, c.data)
-	c.data = c.data[n:]
-
-	c.cond.L.Unlock()
-
-	if n > 0 {
+	// The connection error, including EOF, takes effect only after
+	// all buffered data has been read.
+	if len(c.packets) > 0 {
+		// The datagram is truncated to the buffer size.
+		n = copy(b, c.packets[0])
+		c.packets = c.packets[1:]
+		return n, nil
+	}
+	if len(c.data) > 0 {
+		if c.rchunk > 0 && len(b) > c.rchunk {
+			b = b[:c.rchunk]
+		}
+		n = copy(b, c.data)
+		c.data = c.data[n:]
 		return n, nil
 	}
 
-	return n, c.err
+	return 0, c.err
 }
 
 func (c *WSConn) Write(b []byte) (n int, err error) {
-	c.ws.Send(b)
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed || c.wclosed {
+		return 0, net.ErrClosed
+	}
+	// The data is queued without blocking so the deadline only
+	// applies when it has already passed.
+	if expired(c.wdeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if c.packet {
+		c.wpackets = append(c.wpackets, append([]byte(nil), b...))
+	} else {
+		c.wdata = append(c.wdata, b...)
+	}
+	c.cond.Broadcast()
+
+	return len(b), nil
+}
+
+// WriteOOB writes the data as out-of-band data. The data is sent
+// ahead of the data queued with Write and the proxy forwards it as
+// TCP urgent data. The function returns ErrUnsupported if the proxy
+// does not support urgent data.
+func (c *WSConn) WriteOOB(b []byte) (n int, err error) {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed || c.wclosed {
+		return 0, net.ErrClosed
+	}
+	if !c.urgent {
+		return 0, ErrUnsupported
+	}
+	c.udata = append(c.udata, b...)
+	c.cond.Broadcast()
+
 	return len(b), nil
 }
 
+// WriteQueueDepth returns the number of outbound bytes not yet
+// transmitted to the network. The depth is the sum of the data queued
+// in the connection's write buffer and the data buffered in the
+// browser WebSocket.
+func (c *WSConn) WriteQueueDepth() int {
+	c.cond.L.Lock()
+	pending := len(c.wdata) + len(c.udata)
+	for _, p := range c.wpackets {
+		pending += len(p)
+	}
+	c.cond.L.Unlock()
+
+	return pending + c.ws.BufferedAmount()
+}
+
 func (c *WSConn) Close() error {
+	c.cond.L.Lock()
+	if c.closed {
+		c.cond.L.Unlock()
+		return net.ErrClosed
+	}
+	c.closed = true
+	if c.rtimer != nil {
+		c.rtimer.Stop()
+	}
+	close(c.done)
+	c.cond.Broadcast()
+	for !c.wdone {
+		c.cond.Wait()
+	}
+	c.cond.L.Unlock()
+
 	c.ws.Close()
 	return nil
 }
 
+// CloseRead shuts down the read half of the connection. The pending
+// and subsequent Read calls return io.EOF and the received data is
+// discarded. If the write half is already shut down, the connection
+// is closed.
+func (c *WSConn) CloseRead() error {
+	c.cond.L.Lock()
+	if c.closed || c.rclosed {
+		c.cond.L.Unlock()
+		return net.ErrClosed
+	}
+	c.rclosed = true
+	c.data = nil
+	c.packets = nil
+	c.cond.Broadcast()
+	wclosed := c.wclosed
+	c.cond.L.Unlock()
+
+	if wclosed {
+		return c.Close()
+	}
+	return nil
+}
+
+// CloseWrite shuts down the write half of the connection. The queued
+// data is sent to the peer, followed by a close frame which makes the
+// proxy shut down the writing side of its TCP connection. The
+// subsequent Write calls fail with net.ErrClosed. If the read half is
+// already shut down, the connection is closed.
+func (c *WSConn) CloseWrite() error {
+	c.cond.L.Lock()
+	if c.closed || c.wclosed {
+		c.cond.L.Unlock()
+		return net.ErrClosed
+	}
+	c.wclosed = true
+	c.cond.Broadcast()
+	rclosed := c.rclosed
+	c.cond.L.Unlock()
+
+	if rclosed {
+		return c.Close()
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the connection is
+// closed.
+func (c *WSConn) Done() <-chan struct{} {
+	return c.done
+}
+
 func (c *WSConn) LocalAddr() net.Addr {
 	return c.ws
 }
@@ -297,12 +712,68 @@ func (c *WSConn) SetDeadline(t time.Time) error {
 	return c.SetWriteDeadline(t)
 }
 
+// SetReadDeadline sets the deadline for the Read calls, including the
+// currently blocked ones. After the deadline, Read fails with
+// os.ErrDeadlineExceeded. The zero value disables the deadline.
 func (c *WSConn) SetReadDeadline(t time.Time) error {
-	return fmt.Errorf("SetReadDeadline not implemented yet")
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.rdeadline = t
+	if c.rtimer != nil {
+		c.rtimer.Stop()
+		c.rtimer = nil
+	}
+	if !t.IsZero() {
+		// Wake up the blocked readers when the deadline passes.
+		c.rtimer = time.AfterFunc(time.Until(t), func() {
+			c.cond.L.Lock()
+			c.cond.Broadcast()
+			c.cond.L.Unlock()
+		})
+	}
+	c.cond.Broadcast()
+
+	return nil
 }
 
+// SetWriteDeadline sets the deadline for the Write calls. The writes
+// are queued without blocking so Write fails with
+// os.ErrDeadlineExceeded only if the deadline has passed when Write
+// is called. The zero value disables the deadline.
 func (c *WSConn) SetWriteDeadline(t time.Time) error {
-	return fmt.Errorf("SetWriteDeadline not implemented yet")
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.wdeadline = t
+
+	return nil
+}
+
+// SetReadChunk limits the number of bytes a single Read call returns
+// to n, even if more data is buffered and the caller's buffer is
+// larger. This keeps the processing of large bursts bounded. The
+// value 0 removes the limit.
+func (c *WSConn) SetReadChunk(n int) {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	c.rchunk = n
+}
+
+// expired tests if the deadline has passed. The zero deadline never
+// expires.
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
 }
 
 func (c *WSConn) onData(data []byte) {
@@ -0,0 +1,1218 @@
+//
+// tcp_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+// fakeSocket implements a WebSocket provider and its socket for
+// tests. The resolve requests are answered from the hosts map. If
+// openErr is set, the socket fails with it instead of opening. The
+// dropOnOpen takes the browser offline when the socket is opened. If
+// stall is set, the socket never opens.
+type fakeSocket struct {
+	hosts      map[string][]string
+	offline    bool
+	dropOnOpen bool
+	stall      bool
+	openErr    error
+	url        string
+	protocols  []string
+	header     http.Header
+	sent       []byte
+	buffered   int
+	closed     bool
+	onSend     func(data []byte)
+	cb         Callbacks
+	mutex      sync.Mutex
+	events     chan func()
+}
+
+// install sets the socket as the WebSocket provider for the test. The
+// test cleanup restores the previous provider and stops the event
+// goroutine.
+func (fs *fakeSocket) install(t *testing.T) {
+	fs.events = make(chan func(), 1024)
+	go func() {
+		for event := range fs.events {
+			event()
+		}
+	}()
+	old := SetProvider(fs)
+	t.Cleanup(func() {
+		SetProvider(old)
+		fs.mutex.Lock()
+		close(fs.events)
+		fs.events = nil
+		fs.mutex.Unlock()
+	})
+}
+
+func (fs *fakeSocket) Open(url string, protocols []string,
+	header http.Header, cb Callbacks) Socket {
+
+	fs.url = url
+	fs.protocols = protocols
+	fs.header = header
+	fs.cb = cb
+	if fs.dropOnOpen {
+		fs.offline = true
+	}
+	if fs.stall {
+		return fs
+	}
+	if fs.openErr != nil {
+		fs.post(func() {
+			cb.OnError(fs.openErr)
+		})
+	} else {
+		fs.post(cb.OnOpen)
+	}
+	return fs
+}
+
+func (fs *fakeSocket) Online() bool {
+	return !fs.offline
+}
+
+func (fs *fakeSocket) Send(data []byte) {
+	buf := append([]byte(nil), data...)
+	if strings.HasSuffix(fs.url, "/resolve") {
+		fs.resolve(buf)
+		return
+	}
+	fs.sent = append(fs.sent, buf...)
+	fs.buffered += len(buf)
+	if fs.onSend != nil {
+		fs.onSend(buf)
+	}
+}
+
+func (fs *fakeSocket) BufferedAmount() int {
+	return fs.buffered
+}
+
+func (fs *fakeSocket) Close() {
+	fs.closed = true
+}
+
+// post runs the function from the event goroutine, in order, the
+// same way the browser delivers the WebSocket events. The events
+// posted after the test has completed are dropped.
+func (fs *fakeSocket) post(f func()) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.events != nil {
+		fs.events <- f
+	}
+}
+
+// resolve answers the resolve request from the hosts map.
+func (fs *fakeSocket) resolve(data []byte) {
+	req := new(wsproxy.Resolve)
+	result := new(wsproxy.Addresses)
+
+	err := encoding.Unmarshal(bytes.NewReader(data), req)
+	if err != nil {
+		result.Error = err.Error()
+	} else if addrs, ok := fs.hosts[req.Host]; ok {
+		result.Success = true
+		result.Addrs = addrs
+	} else {
+		result.Error = fmt.Sprintf("no such host: %s", req.Host)
+	}
+	data, err = encoding.Marshal(result)
+	if err != nil {
+		panic(err)
+	}
+	fs.message(data)
+}
+
+// message delivers the data to the socket's onMessage callback.
+func (fs *fakeSocket) message(data []byte) {
+	fs.post(func() {
+		fs.cb.OnMessage(data)
+	})
+}
+
+// text delivers the text message to the socket's onText callback.
+func (fs *fakeSocket) text(text string) {
+	fs.post(func() {
+		fs.cb.OnText(text)
+	})
+}
+
+func (fs *fakeSocket) newConn() *WSConn {
+	ws := &WebSocket{
+		URL:    "ws://proxy/proxy",
+		Socket: fs,
+		C:      make(chan Message),
+	}
+	return NewWSConn(ws, "tcp", "localhost:22")
+}
+
+func TestWriteQueueDepth(t *testing.T) {
+	fs := &fakeSocket{
+		buffered: 100,
+	}
+	fs.install(t)
+	conn := fs.newConn()
+
+	if depth := conn.WriteQueueDepth(); depth != 100 {
+		t.Errorf("WriteQueueDepth: got %d, expected 100", depth)
+	}
+
+	// The write loop has not run yet so the data is in the
+	// connection's write buffer.
+	conn.Write([]byte("0123456789"))
+	if depth := conn.WriteQueueDepth(); depth != 110 {
+		t.Errorf("WriteQueueDepth: got %d, expected 110", depth)
+	}
+
+	// Close flushes the write buffer to the socket.
+	conn.Close()
+	if string(fs.sent) != "\x000123456789" {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, "\x000123456789")
+	}
+	// The socket buffers the frame type byte with the data.
+	if depth := conn.WriteQueueDepth(); depth != 111 {
+		t.Errorf("WriteQueueDepth: got %d, expected 111", depth)
+	}
+	if !fs.closed {
+		t.Errorf("socket not closed")
+	}
+}
+
+func TestWriteOOB(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+
+	if _, err := conn.WriteOOB([]byte{0xff}); err != ErrUnsupported {
+		t.Errorf("WriteOOB: got %v, expected %v", err, ErrUnsupported)
+	}
+	conn.urgent = true
+
+	// The urgent data is sent ahead of the queued data.
+	conn.Write([]byte("data"))
+	n, err := conn.WriteOOB([]byte{0xff, 0xf4})
+	if n != 2 || err != nil {
+		t.Errorf("WriteOOB: got %d, %v", n, err)
+	}
+	if depth := conn.WriteQueueDepth(); depth != 6 {
+		t.Errorf("WriteQueueDepth: got %d, expected 6", depth)
+	}
+	conn.Close()
+
+	var expected []byte
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameUrgent, []byte{0xff, 0xf4})...)
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("data"))...)
+	if !bytes.Equal(fs.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, expected)
+	}
+	if _, err := conn.WriteOOB([]byte{0xff}); err != net.ErrClosed {
+		t.Errorf("WriteOOB: got %v, expected %v", err, net.ErrClosed)
+	}
+}
+
+func TestReadBeforeEOF(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	// Enqueue data and close without reading in between.
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("Hello, ")),
+	}
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("world!")),
+	}
+	conn.ws.C <- Message{
+		Type: Close,
+	}
+
+	var buf [4]byte
+	if n, err := conn.Read(buf[:0]); n != 0 || err != nil {
+		t.Errorf("empty Read: got %d, %v", n, err)
+	}
+
+	var result []byte
+	for {
+		n, err := conn.Read(buf[:])
+		result = append(result, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+	}
+	if string(result) != "Hello, world!" {
+		t.Errorf("Read: got %q, expected %q", result, "Hello, world!")
+	}
+	conn.Close()
+}
+
+func TestReadControlFrames(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	frames := [][]byte{
+		wsproxy.Frame(wsproxy.FrameData, []byte("Hello, ")),
+		wsproxy.Frame(wsproxy.FrameKeepalive, nil),
+		wsproxy.Frame(wsproxy.FrameFlow, []byte{0x00, 0x10, 0x00}),
+		wsproxy.Frame(wsproxy.FrameData, []byte("world!")),
+		wsproxy.Frame(wsproxy.FrameClose, nil),
+		wsproxy.Frame(wsproxy.FrameData, []byte("after close")),
+	}
+	for _, frame := range frames {
+		conn.ws.C <- Message{
+			Type: Data,
+			Data: frame,
+		}
+	}
+
+	var buf [4]byte
+	var result []byte
+	for {
+		n, err := conn.Read(buf[:])
+		result = append(result, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+	}
+	if string(result) != "Hello, world!" {
+		t.Errorf("Read: got %q, expected %q", result, "Hello, world!")
+	}
+	conn.Close()
+}
+
+func TestReadInvalidFrame(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: []byte{0xff, 'x'},
+	}
+	var buf [4]byte
+	n, err := conn.Read(buf[:])
+	if n != 0 || err == nil || err == io.EOF {
+		t.Errorf("Read: got %d, %v, expected frame error", n, err)
+	}
+	conn.Close()
+}
+
+func TestConcurrentReadWriteClose(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	// Echo all sent data back to the connection.
+	echoC := make(chan []byte, 1024)
+	fs.onSend = func(data []byte) {
+		echoC <- data
+	}
+	go func() {
+		for data := range echoC {
+			conn.ws.C <- Message{
+				Type: Data,
+				Data: data,
+			}
+		}
+	}()
+
+	readC := make(chan int)
+	readErrC := make(chan error)
+	go func() {
+		var buf [16]byte
+		for {
+			n, err := conn.Read(buf[:])
+			if err != nil {
+				readErrC <- err
+				return
+			}
+			readC <- n
+		}
+	}()
+
+	writeErrC := make(chan error)
+	go func() {
+		for {
+			_, err := conn.Write([]byte("ping"))
+			if err != nil {
+				writeErrC <- err
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	timeout := time.After(5 * time.Second)
+
+	// Wait until data flows in both directions.
+	select {
+	case <-readC:
+	case err := <-readErrC:
+		t.Fatalf("Read failed: %s", err)
+	case <-timeout:
+		t.Fatalf("no data read")
+	}
+
+	closeErrC := make(chan error)
+	go func() {
+		closeErrC <- conn.Close()
+	}()
+
+	var readErr, writeErr, closeErr error
+	for readErr == nil || writeErr == nil || closeErr == nil {
+		select {
+		case <-readC:
+		case readErr = <-readErrC:
+		case writeErr = <-writeErrC:
+		case err := <-closeErrC:
+			if err != nil {
+				t.Fatalf("Close failed: %s", err)
+			}
+			closeErr = conn.Close()
+		case <-timeout:
+			t.Fatalf("connection did not terminate: read=%v, write=%v",
+				readErr, writeErr)
+		}
+	}
+	if !errors.Is(readErr, net.ErrClosed) {
+		t.Errorf("Read: got %v, expected %v", readErr, net.ErrClosed)
+	}
+	if !errors.Is(writeErr, net.ErrClosed) {
+		t.Errorf("Write: got %v, expected %v", writeErr, net.ErrClosed)
+	}
+	if !errors.Is(closeErr, net.ErrClosed) {
+		t.Errorf("second Close: got %v, expected %v", closeErr, net.ErrClosed)
+	}
+	if !fs.closed {
+		t.Errorf("socket not closed")
+	}
+}
+
+func TestDialHostFailover(t *testing.T) {
+	fs := &fakeSocket{
+		hosts: map[string][]string{
+			"example.com": {"192.0.2.1", "192.0.2.2"},
+		},
+	}
+	fs.install(t)
+
+	var dials []string
+	fs.onSend = func(data []byte) {
+		dial := new(wsproxy.Dial)
+		err := encoding.Unmarshal(bytes.NewReader(data), dial)
+		if err != nil {
+			t.Errorf("invalid dial request: %s", err)
+			return
+		}
+		dials = append(dials, dial.Addr)
+
+		status := &wsproxy.Status{
+			Success: true,
+		}
+		if dial.Addr == "192.0.2.1:22" {
+			status.Success = false
+			status.Error = "connection refused"
+		}
+		data, err = encoding.Marshal(status)
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(data)
+	}
+
+	conn, err := DialTimeout("proxy:8100", "example.com:22", time.Second)
+	if err != nil {
+		t.Fatalf("DialTimeout failed: %s", err)
+	}
+	defer conn.Close()
+
+	expected := []string{"192.0.2.1:22", "192.0.2.2:22"}
+	if !reflect.DeepEqual(dials, expected) {
+		t.Errorf("dials: got %v, expected %v", dials, expected)
+	}
+	if conn.RemoteAddr().String() != "192.0.2.2:22" {
+		t.Errorf("RemoteAddr: got %s, expected 192.0.2.2:22",
+			conn.RemoteAddr())
+	}
+
+	_, err = DialTimeout("proxy:8100", "unknown.example.com:22", time.Second)
+	if err == nil {
+		t.Errorf("DialTimeout to unknown host succeeded")
+	}
+}
+
+func TestDialerProtocolsAndHeader(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		data, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(data)
+	}
+
+	d := &Dialer{
+		Proxy:     "proxy:8100",
+		Timeout:   time.Second,
+		Protocols: []string{"wsproxy.v1", "wsproxy"},
+		Header: http.Header{
+			"Authorization": {"Bearer token"},
+			"X-Route":       {"a", "b"},
+		},
+	}
+	conn, err := d.Dial("tcp", "192.0.2.1:22")
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err)
+	}
+	defer conn.Close()
+
+	if fs.url != "ws://proxy:8100/proxy" {
+		t.Errorf("url: got %s, expected ws://proxy:8100/proxy", fs.url)
+	}
+	if !reflect.DeepEqual(fs.protocols, d.Protocols) {
+		t.Errorf("protocols: got %v, expected %v", fs.protocols, d.Protocols)
+	}
+	if !reflect.DeepEqual(fs.header, d.Header) {
+		t.Errorf("header: got %v, expected %v", fs.header, d.Header)
+	}
+}
+
+func TestDialerRewrite(t *testing.T) {
+	fs := &fakeSocket{
+		hosts: map[string][]string{
+			"internal.example.com": {"192.0.2.7"},
+		},
+	}
+	fs.install(t)
+
+	var dials []string
+	fs.onSend = func(data []byte) {
+		dial := new(wsproxy.Dial)
+		err := encoding.Unmarshal(bytes.NewReader(data), dial)
+		if err != nil {
+			t.Errorf("invalid dial request: %s", err)
+			return
+		}
+		dials = append(dials, dial.Addr)
+
+		data, err = encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(data)
+	}
+
+	errBlocked := errors.New("destination blocked")
+	d := &Dialer{
+		Proxy:   "proxy:8100",
+		Timeout: time.Second,
+		Rewrite: func(network, addr string) (string, string, error) {
+			switch addr {
+			case "db:5432":
+				return network, "internal.example.com:5432", nil
+			case "192.0.2.66:25":
+				return "", "", errBlocked
+			}
+			return network, addr, nil
+		},
+	}
+	conn, err := d.Dial("tcp", "db:5432")
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err)
+	}
+	defer conn.Close()
+
+	if conn.RemoteAddr().String() != "192.0.2.7:5432" {
+		t.Errorf("RemoteAddr: got %s, expected 192.0.2.7:5432",
+			conn.RemoteAddr())
+	}
+
+	_, err = d.Dial("tcp", "192.0.2.66:25")
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("Dial: got %v, expected %v", err, errBlocked)
+	}
+
+	expected := []string{"192.0.2.7:5432"}
+	if !reflect.DeepEqual(dials, expected) {
+		t.Errorf("dials: got %v, expected %v", dials, expected)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+
+	ws := NewWebSocket("ws://proxy:8100/proxy", nil, nil)
+	if fs.url != ws.URL {
+		t.Errorf("url: got %s, expected %s", fs.url, ws.URL)
+	}
+	msg := <-ws.C
+	if msg.Type != Open {
+		t.Fatalf("unexpected message %s, expected Open", msg.String())
+	}
+
+	ws.Send([]byte("ping"))
+	if string(fs.sent) != "ping" {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, "ping")
+	}
+	if ws.BufferedAmount() != 4 {
+		t.Errorf("BufferedAmount: got %d, expected 4", ws.BufferedAmount())
+	}
+
+	fs.message([]byte("pong"))
+	msg = <-ws.C
+	if msg.Type != Data || string(msg.Data) != "pong" {
+		t.Errorf("unexpected message %s, expected Data=pong", msg.String())
+	}
+
+	ws.Close()
+	if !fs.closed {
+		t.Errorf("socket not closed")
+	}
+}
+
+func TestDialError(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		data, err := encoding.Marshal(&wsproxy.Status{
+			Error: "connect: connection refused",
+			Code:  wsproxy.ErrorRefused,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(data)
+	}
+
+	_, err := DialTimeout("proxy:8100", "192.0.2.1:22", time.Second)
+	if err == nil {
+		t.Fatalf("DialTimeout succeeded")
+	}
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("DialTimeout: got %T, expected *DialError", err)
+	}
+	if dialErr.Addr != "192.0.2.1:22" {
+		t.Errorf("Addr: got %s, expected 192.0.2.1:22", dialErr.Addr)
+	}
+	if dialErr.Message != "connect: connection refused" {
+		t.Errorf("Message: got %q", dialErr.Message)
+	}
+	if dialErr.Code != wsproxy.ErrorRefused {
+		t.Errorf("Code: got %s, expected %s", dialErr.Code,
+			wsproxy.ErrorRefused)
+	}
+	if dialErr.Code.String() != "Connection refused" {
+		t.Errorf("Code.String: got %q", dialErr.Code.String())
+	}
+}
+
+func TestDialNoTimeout(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		data, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(data)
+	}
+
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		conn, err := DialTimeout("proxy:8100", "192.0.2.1:22", timeout)
+		if err != nil {
+			t.Errorf("DialTimeout(%s) failed: %s", timeout, err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+func TestDialOffline(t *testing.T) {
+	fs := &fakeSocket{
+		offline: true,
+	}
+	fs.install(t)
+
+	_, err := DialTimeout("proxy:8100", "192.0.2.1:22", time.Second)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("DialTimeout: got %v, expected %v", err, ErrOffline)
+	}
+	if len(fs.url) != 0 {
+		t.Errorf("offline dial opened WebSocket %s", fs.url)
+	}
+
+	// The browser goes offline while the WebSocket is connecting.
+	fs = &fakeSocket{
+		dropOnOpen: true,
+		openErr:    errors.New("error"),
+	}
+	fs.install(t)
+
+	_, err = DialTimeout("proxy:8100", "192.0.2.1:22", time.Second)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("DialTimeout: got %v, expected %v", err, ErrOffline)
+	}
+
+	// Errors are reported as-is while online.
+	fs = &fakeSocket{
+		openErr: errors.New("error"),
+	}
+	fs.install(t)
+
+	_, err = DialTimeout("proxy:8100", "192.0.2.1:22", time.Second)
+	if err == nil || errors.Is(err, ErrOffline) {
+		t.Errorf("DialTimeout: got %v, expected socket error", err)
+	}
+}
+
+func TestTextMessage(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+
+	ws := NewWebSocket("ws://proxy/echo", nil, nil)
+	if msg := <-ws.C; msg.Type != Open {
+		t.Fatalf("expected Open, got %s", msg.String())
+	}
+	fs.text("Hyvää päivää")
+	msg := <-ws.C
+	if msg.Type != Data || !msg.Text {
+		t.Errorf("expected text message, got %s", msg.String())
+	}
+	if !bytes.Equal(msg.Data, []byte("Hyvää päivää")) {
+		t.Errorf("text: got %x, expected UTF-8 %q",
+			msg.Data, "Hyvää päivää")
+	}
+	ws.Close()
+
+	// The proxy connections are binary-only.
+	fs.onSend = func(data []byte) {
+		fs.text("status")
+	}
+	_, err := DialTimeout("proxy:8100", "192.0.2.1:22", time.Second)
+	if !errors.Is(err, ErrTextMessage) {
+		t.Errorf("DialTimeout: got %v, expected %v", err, ErrTextMessage)
+	}
+
+	conn := fs.newConn()
+	go conn.messageLoop()
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("binary")),
+	}
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: []byte("text"),
+		Text: true,
+	}
+	var buf [64]byte
+	n, err := conn.Read(buf[:])
+	if err != nil || string(buf[:n]) != "binary" {
+		t.Errorf("Read: got %q, %v", buf[:n], err)
+	}
+	_, err = conn.Read(buf[:])
+	if !errors.Is(err, ErrTextMessage) {
+		t.Errorf("Read: got %v, expected %v", err, ErrTextMessage)
+	}
+	conn.Close()
+}
+
+func TestDeadlines(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+
+	// A blocked Read returns a timeout error at the deadline.
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(50 * time.Millisecond))
+	var buf [64]byte
+	_, err := conn.Read(buf[:])
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Read: got %v, expected timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Read returned before the deadline: %s", elapsed)
+	}
+
+	// Setting the deadline wakes up a blocked Read.
+	errC := make(chan error)
+	go func() {
+		_, err := conn.Read(buf[:])
+		errC <- err
+	}()
+	conn.SetReadDeadline(time.Time{})
+	time.Sleep(10 * time.Millisecond)
+	conn.SetReadDeadline(time.Now().Add(-time.Second))
+	select {
+	case err := <-errC:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("Read: got %v, expected %v", err,
+				os.ErrDeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Read not woken up by SetReadDeadline")
+	}
+
+	// The zero deadline disables the timeout.
+	conn.SetReadDeadline(time.Time{})
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("data")),
+	}
+	n, err := conn.Read(buf[:])
+	if err != nil || string(buf[:n]) != "data" {
+		t.Errorf("Read: got %q, %v", buf[:n], err)
+	}
+
+	// Write fails immediately with a deadline in the past.
+	conn.SetWriteDeadline(time.Now().Add(-time.Second))
+	if _, err := conn.Write([]byte("x")); !errors.Is(err,
+		os.ErrDeadlineExceeded) {
+		t.Errorf("Write: got %v, expected %v", err, os.ErrDeadlineExceeded)
+	}
+	conn.SetWriteDeadline(time.Time{})
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+
+	conn.Close()
+	if err := conn.SetReadDeadline(time.Now()); err != net.ErrClosed {
+		t.Errorf("SetReadDeadline: got %v, expected %v", err, net.ErrClosed)
+	}
+}
+
+func TestCloseAfterPeerClose(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := NewWSConn(NewWebSocket("ws://proxy/proxy", nil, nil),
+		"tcp", "192.0.2.1:22")
+	if msg := <-conn.ws.C; msg.Type != Open {
+		t.Fatalf("expected Open, got %s", msg.String())
+	}
+	loopDone := make(chan struct{})
+	go func() {
+		conn.messageLoop()
+		close(loopDone)
+	}()
+
+	// The peer writes its final response and closes immediately.
+	payload := strings.Repeat("response ", 100)
+	for i := 0; i < len(payload); i += 64 {
+		end := i + 64
+		if end > len(payload) {
+			end = len(payload)
+		}
+		fs.message(wsproxy.Frame(wsproxy.FrameData, []byte(payload[i:end])))
+	}
+	fs.post(fs.cb.OnClose)
+
+	var result []byte
+	var buf [100]byte
+	for {
+		n, err := conn.Read(buf[:])
+		result = append(result, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+	}
+	if string(result) != payload {
+		t.Errorf("Read: got %d bytes, expected %d", len(result), len(payload))
+	}
+	conn.Close()
+	<-loopDone
+}
+
+func TestCloseStopsMessageLoop(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	loopDone := make(chan struct{})
+	go func() {
+		conn.messageLoop()
+		close(loopDone)
+	}()
+
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("data")),
+	}
+	conn.Close()
+
+	select {
+	case <-loopDone:
+	case <-time.After(time.Second):
+		t.Fatalf("messageLoop not terminated by Close")
+	}
+	conn.cond.L.Lock()
+	data := string(conn.data)
+	conn.cond.L.Unlock()
+	if data != "data" {
+		t.Errorf("buffered data: got %q, expected %q", data, "data")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	// Cancel while the WebSocket is opening.
+	fs := &fakeSocket{
+		stall: true,
+	}
+	fs.install(t)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	_, err := DialContext(ctx, "proxy:8100", "192.0.2.1:22")
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialContext: got %v, expected %v", err,
+			context.DeadlineExceeded)
+	}
+	if !fs.closed {
+		t.Errorf("WebSocket not closed")
+	}
+
+	// Cancel while waiting for the dial status.
+	fs = new(fakeSocket)
+	fs.install(t)
+	ctx, cancel = context.WithCancel(context.Background())
+	fs.onSend = func(data []byte) {
+		cancel()
+	}
+	_, err = DialContext(ctx, "proxy:8100", "192.0.2.1:22")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DialContext: got %v, expected %v", err, context.Canceled)
+	}
+	if !fs.closed {
+		t.Errorf("WebSocket not closed")
+	}
+
+	// Cancel after the connection is established.
+	fs = new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		status, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(status)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	conn, err := DialContext(ctx, "proxy:8100", "192.0.2.1:22")
+	if err != nil {
+		t.Fatalf("DialContext failed: %s", err)
+	}
+	cancel()
+	if fs.closed {
+		t.Errorf("WebSocket closed by cancel after dial")
+	}
+	fs.onSend = nil
+	if _, err := conn.Write([]byte("data")); err != nil {
+		t.Errorf("Write failed: %s", err)
+	}
+	conn.Close()
+	if !fs.closed {
+		t.Errorf("WebSocket not closed")
+	}
+}
+
+func TestDialerDefaults(t *testing.T) {
+	tests := []struct {
+		timeout  time.Duration
+		deadline time.Duration
+	}{
+		// The dialer's timeout expires first.
+		{50 * time.Millisecond, 10 * time.Second},
+		// The context's deadline expires first.
+		{10 * time.Second, 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		fs := new(fakeSocket)
+		fs.install(t)
+		var timeout time.Duration
+		fs.onSend = func(data []byte) {
+			dial := new(wsproxy.Dial)
+			err := encoding.Unmarshal(bytes.NewReader(data), dial)
+			if err != nil {
+				t.Errorf("invalid dial request: %s", err)
+				return
+			}
+			timeout = dial.Timeout
+		}
+		d := &Dialer{
+			Proxy:   "proxy:8100",
+			Timeout: test.timeout,
+		}
+		ctx, cancel := context.WithTimeout(context.Background(),
+			test.deadline)
+		start := time.Now()
+		_, err := d.DialContext(ctx, "tcp", "192.0.2.1:22")
+		elapsed := time.Since(start)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("DialContext: got %v, expected %v", err,
+				context.DeadlineExceeded)
+		}
+		if elapsed > 5*time.Second {
+			t.Errorf("DialContext returned after %v", elapsed)
+		}
+		if timeout <= 0 || timeout > 50*time.Millisecond {
+			t.Errorf("dial request timeout %v", timeout)
+		}
+		if !fs.closed {
+			t.Errorf("WebSocket not closed")
+		}
+	}
+
+	// The keepalive and read chunk settings apply to the connection.
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		status, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(status)
+	}
+	d := &Dialer{
+		Proxy:     "proxy:8100",
+		KeepAlive: 10 * time.Millisecond,
+		ReadChunk: 4,
+	}
+	conn, err := d.Dial("tcp", "192.0.2.1:22")
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err)
+	}
+	defer conn.Close()
+	fs.onSend = nil
+	fs.sent = nil
+
+	time.Sleep(50 * time.Millisecond)
+	keepalive := wsproxy.Frame(wsproxy.FrameKeepalive, nil)
+	if !bytes.HasPrefix(fs.sent, keepalive) {
+		t.Errorf("no keepalive frames sent: %q", fs.sent)
+	}
+
+	conn.(*WSConn).ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, []byte("0123456789")),
+	}
+	var buf [16]byte
+	n, err := conn.Read(buf[:])
+	if err != nil || string(buf[:n]) != "0123" {
+		t.Errorf("Read: got %q, %v, expected \"0123\"", buf[:n], err)
+	}
+}
+
+func TestDialRaw(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		status, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(status)
+	}
+	conn, ws, err := DialRaw("proxy:8100", "192.0.2.1:22", time.Second)
+	if err != nil {
+		t.Fatalf("DialRaw failed: %s", err)
+	}
+	fs.onSend = nil
+	if ws != conn.ws {
+		t.Errorf("DialRaw: WebSocket does not drive the connection")
+	}
+	if ws.URL != "ws://proxy:8100/proxy" || ws.Socket != Socket(fs) {
+		t.Errorf("DialRaw: unexpected WebSocket %s", ws)
+	}
+
+	// The raw messages and the connection data share the socket.
+	fs.sent = nil
+	ws.Send(wsproxy.Frame(wsproxy.FrameKeepalive, nil))
+	conn.Write([]byte("data"))
+	conn.Close()
+
+	var expected []byte
+	expected = append(expected, wsproxy.Frame(wsproxy.FrameKeepalive, nil)...)
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("data"))...)
+	if !bytes.Equal(fs.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, expected)
+	}
+}
+
+func TestSetReadChunk(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	conn := fs.newConn()
+	go conn.messageLoop()
+	defer conn.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 1024)
+	conn.ws.C <- Message{
+		Type: Data,
+		Data: wsproxy.Frame(wsproxy.FrameData, data),
+	}
+	conn.ws.C <- Message{
+		Type: Close,
+	}
+
+	const chunk = 1500
+	conn.SetReadChunk(chunk)
+	limit := chunk
+
+	var result []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if n > limit {
+			t.Errorf("Read returned %d bytes, limit %d", n, limit)
+		}
+		result = append(result, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+		if len(result) == 3*chunk {
+			// Remove the limit for the rest of the data.
+			conn.SetReadChunk(0)
+			limit = len(buf)
+		}
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("Read: got %d bytes, expected %d", len(result), len(data))
+	}
+}
+
+func TestCompression(t *testing.T) {
+	fa, fb := new(fakeSocket), new(fakeSocket)
+	fa.install(t)
+	fb.install(t)
+	a, b := fa.newConn(), fb.newConn()
+	a.compress, b.compress = true, true
+	go a.messageLoop()
+	go b.messageLoop()
+	defer a.Close()
+	defer b.Close()
+
+	// Connect the sockets back to back.
+	link := func(from *fakeSocket, to *WSConn) {
+		from.onSend = func(data []byte) {
+			from.post(func() {
+				to.ws.C <- Message{
+					Type: Data,
+					Data: data,
+				}
+			})
+		}
+	}
+	link(fa, b)
+	link(fb, a)
+
+	data := bytes.Repeat([]byte("compressible data "), 1000)
+	if _, err := a.Write(data); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(b, buf); err != nil {
+		t.Fatalf("Read failed: %s", err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Errorf("Read: data mismatch")
+	}
+	if len(fa.sent) >= len(data) {
+		t.Errorf("compressed size %d, uncompressed %d",
+			len(fa.sent), len(data))
+	}
+	if fa.sent[0] != byte(wsproxy.FrameDeflate) {
+		t.Errorf("frame type: got %v", wsproxy.FrameType(fa.sent[0]))
+	}
+
+	// The incompressible data is sent as-is.
+	if _, err := b.Write([]byte("ok")); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if n, err := io.ReadFull(a, buf[:2]); err != nil || n != 2 ||
+		string(buf[:2]) != "ok" {
+		t.Errorf("Read: got %q, %v", buf[:n], err)
+	}
+	expected := wsproxy.Frame(wsproxy.FrameData, []byte("ok"))
+	if !bytes.Equal(fb.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fb.sent, expected)
+	}
+
+	// The compressed frames are rejected if not negotiated.
+	c := fa.newConn()
+	defer c.Close()
+	c.frame(wsproxy.DataFrame(data, true))
+	if c.err == nil {
+		t.Errorf("unexpected compressed frame accepted")
+	}
+}
@@ -0,0 +1,55 @@
+//
+// tls.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// DialTLS connects to the address addr through the WebSocket proxy
+// and performs the TLS client handshake over the connection. A nil
+// config uses the default configuration. If the config does not
+// specify ServerName, it is set from the host part of addr. The
+// handshake runs with a read and write deadline of
+// DefaultDialTimeout so it depends on the connection's deadline
+// support. The deadline is cleared after the handshake.
+func DialTLS(proxy, addr string, cfg *tls.Config) (net.Conn, error) {
+	host, _, err := ParseAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = new(tls.Config)
+	}
+	if len(cfg.ServerName) == 0 {
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+	conn, err := DialTimeout(proxy, addr, DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(DefaultDialTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,160 @@
+//
+// tls_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+// newCertificate creates a self-signed certificate for the host.
+func newCertificate(t *testing.T, host string) (tls.Certificate,
+	*x509.CertPool) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: host,
+		},
+		DNSNames:    []string{host},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, pool
+}
+
+// tlsServer connects the fake socket to a TLS server which sends the
+// message to the client and closes the connection.
+func tlsServer(t *testing.T, fs *fakeSocket, cert tls.Certificate,
+	msg string) {
+
+	client, server := net.Pipe()
+	go func() {
+		var buf [4096]byte
+		for {
+			n, err := client.Read(buf[:])
+			if err != nil {
+				fs.message(wsproxy.Frame(wsproxy.FrameClose,
+					nil))
+				return
+			}
+			fs.message(wsproxy.Frame(wsproxy.FrameData,
+				append([]byte(nil), buf[:n]...)))
+		}
+	}()
+	go func() {
+		conn := tls.Server(server, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})
+		conn.Write([]byte(msg))
+		conn.Close()
+	}()
+
+	dialed := false
+	fs.onSend = func(data []byte) {
+		if !dialed {
+			dialed = true
+			status, err := encoding.Marshal(&wsproxy.Status{
+				Success: true,
+			})
+			if err != nil {
+				t.Errorf("marshal status: %s", err)
+				return
+			}
+			fs.message(status)
+			return
+		}
+		typ, payload, err := wsproxy.ParseFrame(data)
+		if err != nil {
+			t.Errorf("invalid frame: %s", err)
+			return
+		}
+		if typ == wsproxy.FrameData {
+			client.Write(payload)
+		}
+	}
+}
+
+func TestDialTLS(t *testing.T) {
+	cert, pool := newCertificate(t, "server.example")
+
+	fs := &fakeSocket{
+		hosts: map[string][]string{
+			"server.example": {"192.0.2.1"},
+		},
+	}
+	fs.install(t)
+	tlsServer(t, fs, cert, "Hello, TLS!")
+
+	// The server name is derived from the address.
+	conn, err := DialTLS("proxy:8100", "server.example:443", &tls.Config{
+		RootCAs: pool,
+	})
+	if err != nil {
+		t.Fatalf("DialTLS failed: %s", err)
+	}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		t.Fatalf("DialTLS returned %T", conn)
+	}
+	name := tlsConn.ConnectionState().ServerName
+	if name != "server.example" {
+		t.Errorf("ServerName: got %q, expected server.example", name)
+	}
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("Read failed: %s", err)
+	}
+	if string(data) != "Hello, TLS!" {
+		t.Errorf("Read: got %q, expected %q", data, "Hello, TLS!")
+	}
+	conn.Close()
+
+	// The default configuration does not trust the server.
+	fs = &fakeSocket{}
+	fs.install(t)
+	tlsServer(t, fs, cert, "")
+	_, err = DialTLS("proxy:8100", "192.0.2.1:443", nil)
+	if err == nil {
+		t.Errorf("DialTLS with an untrusted certificate succeeded")
+	}
+}
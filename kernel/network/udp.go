@@ -0,0 +1,43 @@
+//
+// udp.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"net"
+)
+
+// UDPConn implements a connected UDP socket over a WebSocket proxy
+// connection. It implements both net.Conn and net.PacketConn. Each
+// datagram is carried in its own proxy data frame so the datagram
+// boundaries are preserved. A Read with a buffer smaller than the
+// datagram returns the beginning of the datagram and discards the
+// rest.
+type UDPConn struct {
+	*WSConn
+}
+
+// ReadFrom reads one datagram into b. The returned address is the
+// connection's remote address.
+func (c *UDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, c.RemoteAddr(), nil
+}
+
+// WriteTo writes the datagram b. Since the connection is connected,
+// the address must be nil or the connection's remote address.
+// Otherwise WriteTo fails with net.ErrWriteToConnected.
+func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if addr != nil && addr.String() != c.RemoteAddr().String() {
+		return 0, net.ErrWriteToConnected
+	}
+	return c.Write(b)
+}
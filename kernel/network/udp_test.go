@@ -0,0 +1,103 @@
+//
+// udp_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package network
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/encoding"
+	"github.com/markkurossi/blackbox-os/lib/wsproxy"
+)
+
+var _ net.PacketConn = (*UDPConn)(nil)
+
+func TestDialUDP(t *testing.T) {
+	fs := new(fakeSocket)
+	fs.install(t)
+	fs.onSend = func(data []byte) {
+		dial := new(wsproxy.Dial)
+		err := encoding.Unmarshal(bytes.NewReader(data), dial)
+		if err != nil {
+			t.Errorf("unmarshal dial: %s", err)
+			return
+		}
+		if dial.Network != "udp" {
+			t.Errorf("dial network: got %q, expected udp",
+				dial.Network)
+		}
+		status, err := encoding.Marshal(&wsproxy.Status{
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("marshal status: %s", err)
+			return
+		}
+		fs.message(status)
+	}
+	conn, err := DialUDP("proxy:8100", "192.0.2.1:53", time.Second)
+	if err != nil {
+		t.Fatalf("DialUDP failed: %s", err)
+	}
+	fs.onSend = nil
+	fs.sent = nil
+
+	if network := conn.RemoteAddr().Network(); network != "udp" {
+		t.Errorf("RemoteAddr: got network %s", network)
+	}
+
+	// Each datagram is sent in its own frame.
+	conn.WriteTo([]byte("one"), nil)
+	conn.WriteTo([]byte("two"), conn.RemoteAddr())
+	other := &net.UDPAddr{
+		IP:   net.ParseIP("192.0.2.2"),
+		Port: 53,
+	}
+	_, err = conn.WriteTo([]byte("x"), other)
+	if err != net.ErrWriteToConnected {
+		t.Errorf("WriteTo other address: got %v", err)
+	}
+
+	for _, msg := range []string{"first", "second", "truncated datagram"} {
+		conn.ws.C <- Message{
+			Type: Data,
+			Data: wsproxy.Frame(wsproxy.FrameData, []byte(msg)),
+		}
+	}
+	var buf [16]byte
+	for _, expected := range []string{"first", "second"} {
+		n, addr, err := conn.ReadFrom(buf[:])
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %s", err)
+		}
+		if string(buf[:n]) != expected {
+			t.Errorf("ReadFrom: got %q, expected %q",
+				buf[:n], expected)
+		}
+		if addr != conn.RemoteAddr() {
+			t.Errorf("ReadFrom: got address %s", addr)
+		}
+	}
+	n, err := conn.Read(buf[:4])
+	if err != nil || string(buf[:n]) != "trun" {
+		t.Errorf("truncated Read: got %q, %v", buf[:n], err)
+	}
+	conn.Close()
+
+	var expected []byte
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("one"))...)
+	expected = append(expected,
+		wsproxy.Frame(wsproxy.FrameData, []byte("two"))...)
+	if !bytes.Equal(fs.sent, expected) {
+		t.Errorf("sent data: got %q, expected %q", fs.sent, expected)
+	}
+}
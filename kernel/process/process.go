@@ -7,6 +7,8 @@
 package process
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,6 +34,9 @@ var (
 	uint8Array    = js.Global().Get("Uint8Array")
 )
 
+// ErrNotFound is returned when the command program does not exist.
+var ErrNotFound = errors.New("command not found")
+
 var (
 	byID   = make(map[int]*Process)
 	nextID = 0
@@ -46,6 +51,8 @@ type Process struct {
 	FDs      map[int]iface.FD
 	FS       *fs.FS
 	nextFD   int
+	worker   js.Value
+	result   chan error
 }
 
 func New(stdin, stdout, stderr iface.FD, z *zone.Zone) (*Process, error) {
@@ -87,6 +94,35 @@ func (p *Process) Exit(code int) {
 	p.cond.L.Unlock()
 }
 
+// Kill terminates the process with the exit code. It does nothing if
+// the process has already exited.
+func (p *Process) Kill(code int) {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	if p.exited {
+		return
+	}
+	p.exitCode = code
+	p.exited = true
+	p.cond.Signal()
+	p.terminate()
+}
+
+// terminate terminates the process' worker and completes its Exec. The
+// caller must hold the process lock.
+func (p *Process) terminate() {
+	if !p.worker.Truthy() {
+		// Exec terminates the worker when it starts.
+		return
+	}
+	p.worker.Call("terminate")
+	select {
+	case p.result <- nil:
+	default:
+	}
+}
+
 func (p *Process) Wait() int {
 	p.cond.L.Lock()
 	for !p.exited {
@@ -103,10 +139,44 @@ func (p *Process) NewFD(impl iface.FD) int {
 	return fd
 }
 
+// Load loads the program of the command. It returns an error
+// wrapping ErrNotFound if the program does not exist.
+func Load(cmd string) ([]byte, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/bin/%s.wasm?__t=%d",
+		control.BaseURL, cmd, time.Now().Unix()))
+	if err != nil {
+		return nil, fmt.Errorf("process: load %v: %w", cmd, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("process: load %v: %w", cmd, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("process: load %v: %s", cmd, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("process: read data: %w", err)
+	}
+
+	return data, nil
+}
+
+// Run loads the program of the command and runs it with the
+// arguments.
 func (p *Process) Run(cmd string, args []string) error {
+	data, err := Load(cmd)
+	if err != nil {
+		return err
+	}
+	return p.Exec(data, cmd, args)
+}
+
+// Exec runs the program data as the command with the arguments.
+func (p *Process) Exec(data []byte, cmd string, args []string) error {
 	var worker js.Value
 
-	c := make(chan error)
+	c := make(chan error, 1)
 
 	onSyscall := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		if len(args) != 1 {
@@ -128,19 +198,6 @@ func (p *Process) Run(cmd string, args []string) error {
 		return nil
 	})
 
-	resp, err := http.Get(fmt.Sprintf("%s/bin/%s.wasm?__t=%d",
-		control.BaseURL, cmd, time.Now().Unix()))
-	if err != nil {
-		return fmt.Errorf("process: load %v: %w", cmd, err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("process: load %v: %s", cmd, resp.Status)
-	}
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("process: read data: %w", err)
-	}
-
 	code := uint8Array.New(len(data))
 	js.CopyBytesToJS(code, data)
 
@@ -153,6 +210,15 @@ func (p *Process) Run(cmd string, args []string) error {
 
 	worker = syscallSpawn.Invoke(argv...)
 
+	p.cond.L.Lock()
+	p.worker = worker
+	p.result = c
+	if p.exited {
+		// The process was killed before its worker started.
+		p.terminate()
+	}
+	p.cond.L.Unlock()
+
 	return <-c
 }
 
@@ -315,6 +381,36 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 			}
 			syscallResult.Invoke(worker, id, nil, 0)
 
+		case "WaitInterrupt":
+			switch native := f.Native().(type) {
+			case *tty.Console:
+				native.WaitInterrupt()
+
+			default:
+				return errno.EBADF
+			}
+			syscallResult.Invoke(worker, id, nil, 0)
+
+		case "GetWinSize":
+			var cols, rows int
+			switch native := f.Native().(type) {
+			case *tty.Console:
+				size, _ := native.Size()
+				cols, rows = size.X, size.Y
+
+			default:
+				return errno.EBADF
+			}
+			// The size is returned as big-endian 16-bit columns and
+			// rows.
+			var data [4]byte
+			binary.BigEndian.PutUint16(data[0:], uint16(cols))
+			binary.BigEndian.PutUint16(data[2:], uint16(rows))
+
+			buf := uint8Array.New(len(data))
+			js.CopyBytesToJS(buf, data[:])
+			syscallResult.Invoke(worker, id, nil, len(data), buf)
+
 		default:
 			kmsg.Printf("syscall ioctl: %s not implemented yet\n",
 				event.Get("request").String())
@@ -343,6 +439,10 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		js.CopyBytesToJS(buf, data)
 		syscallResult.Invoke(worker, id, nil, len(data), buf)
 
+	case "uptime":
+		ms := int(control.Uptime() / time.Millisecond)
+		syscallResult.Invoke(worker, id, nil, ms)
+
 	case "readdir":
 		path, err := getString(event, "path")
 		if err != nil {
@@ -371,6 +471,15 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return errno.EINVAL
 		}
+		// The program is loaded before the process is created so
+		// that a missing program fails the spawn.
+		data, err := Load(argv[0])
+		if errors.Is(err, ErrNotFound) {
+			return errno.ENOENT
+		} else if err != nil {
+			kmsg.Printf("spawn: %s\n", err)
+			return errno.EIO
+		}
 		process, err := New(nil, nil, nil, p.FS.Zone())
 		if err != nil {
 			return errno.EINVAL
@@ -385,7 +494,7 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		}
 
 		go func() {
-			err := process.Run(argv[0], argv[1:])
+			err := process.Exec(data, argv[0], argv[1:])
 			if err != nil {
 				fmt.Printf("process terminated: %v\n", err)
 				process.Exit(1)
@@ -405,6 +514,26 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		code := process.Wait()
 		syscallResult.Invoke(worker, id, nil, code)
 
+	case "kill":
+		pid, err := getInt(event, "pid")
+		if err != nil {
+			return err
+		}
+		signal, err := getInt(event, "signal")
+		if err != nil {
+			return err
+		}
+		if signal <= 0 {
+			return errno.EINVAL
+		}
+		process, ok := byID[pid]
+		if !ok {
+			return errno.ENOENT
+		}
+		// The killed process exits with the status 128+signal.
+		process.Kill(128 + signal)
+		syscallResult.Invoke(worker, id, nil, 0)
+
 	case "exit":
 		code, err := getInt(event, "code")
 		if err != nil {
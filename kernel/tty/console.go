@@ -78,6 +78,7 @@ type Console struct {
 	lastRune    rune
 	emulator    *vt100.Emulator
 	display     *vt100.Display
+	interrupts  int
 }
 
 // Canonical provides canonical input mode with Emacs-like line
@@ -285,7 +286,9 @@ func (c *Console) Read(p []byte) (int, error) {
 	var n int
 
 	if (c.flags & ICANON) != 0 {
-		for len(c.qCanon.avail) == 0 {
+		// The interrupt ends the read with no data.
+		interrupts := c.interrupts
+		for len(c.qCanon.avail) == 0 && c.interrupts == interrupts {
 			c.cond.Wait()
 		}
 		n = copy(p, c.qCanon.avail)
@@ -391,6 +394,16 @@ func (c *Console) onKey(kt KeyType, code rune) {
 	defer c.cond.L.Unlock()
 
 	if (c.flags & ICANON) != 0 {
+		if kt == KeyCode && code == 0x03 {
+			// Ctrl-C discards the line and interrupts the
+			// readers and the WaitInterrupt callers.
+			c.qCanon.cursor = 0
+			c.qCanon.tail = 0
+			c.Echo([]int{'^', 'C', '\r', '\n'})
+			c.interrupts++
+			c.cond.Broadcast()
+			return
+		}
 		if c.qCanon.input(c, kt, code) {
 			c.emulator.Input('\r')
 			c.emulator.Input('\n')
@@ -433,6 +446,18 @@ func (c *Console) onKey(kt KeyType, code rune) {
 	}
 }
 
+// WaitInterrupt waits until Ctrl-C is pressed in the canonical input
+// mode. In the raw mode, Ctrl-C is read as the 0x03 character.
+func (c *Console) WaitInterrupt() {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	interrupts := c.interrupts
+	for c.interrupts == interrupts {
+		c.cond.Wait()
+	}
+}
+
 func (c *Console) Echo(code []int) {
 	if (c.flags & ECHO) != 0 {
 		for _, co := range code {
@@ -7,6 +7,7 @@
 package bbos
 
 import (
+	"encoding/binary"
 	"fmt"
 )
 
@@ -37,3 +38,36 @@ func SetFlags(fd, flags int) error {
 	})
 	return err
 }
+
+// WaitInterrupt waits until the terminal fd is interrupted with
+// Ctrl-C.
+func WaitInterrupt(fd int) error {
+	_, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "WaitInterrupt",
+	})
+	return err
+}
+
+// GetWinSize returns the size of the terminal fd in character cells.
+func GetWinSize(fd int) (cols, rows int, err error) {
+	data, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "GetWinSize",
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	val, ok := data["buf"]
+	if !ok {
+		return 0, 0, fmt.Errorf("GetWinSize: invalid response")
+	}
+	buf, ok := val.([]byte)
+	if !ok || len(buf) != 4 {
+		return 0, 0, fmt.Errorf("GetWinSize: invalid response")
+	}
+	cols = int(binary.BigEndian.Uint16(buf[0:]))
+	rows = int(binary.BigEndian.Uint16(buf[2:]))
+
+	return cols, rows, nil
+}
@@ -7,9 +7,17 @@
 package bbos
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrNotFound is returned by Spawn when the command program does not
+// exist.
+var ErrNotFound = errors.New("command not found")
+
+// Spawn starts the command argv[0] with the arguments argv[1:]. The
+// fds are the file descriptors of the standard input, output, and
+// error of the process. It returns the process ID.
 func Spawn(argv []string, fds []int) (int, error) {
 	var iargv []interface{}
 	for _, arg := range argv {
@@ -26,6 +34,9 @@ func Spawn(argv []string, fds []int) (int, error) {
 		"fds":  ifds,
 	})
 	if err != nil {
+		if err.Error() == "ENOENT" {
+			return 0, ErrNotFound
+		}
 		return 0, err
 	}
 	pid, ok := data["ret"]
@@ -56,3 +67,13 @@ func Wait(pid int) (int, error) {
 	}
 	return icode, nil
 }
+
+// Kill sends the signal to the process. The process terminates with
+// the exit status 128+signal.
+func Kill(pid, signal int) error {
+	_, err := Syscall("kill", map[string]interface{}{
+		"pid":    pid,
+		"signal": signal,
+	})
+	return err
+}
@@ -0,0 +1,29 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+	"time"
+)
+
+// Uptime returns how long the kernel has been running.
+func Uptime() (time.Duration, error) {
+	data, err := Syscall("uptime", map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	val, ok := data["ret"]
+	if !ok {
+		return 0, fmt.Errorf("Uptime: invalid response")
+	}
+	ms, ok := val.(int)
+	if !ok {
+		return 0, fmt.Errorf("Uptime: invalid response")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,164 @@
+//
+// history.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/markkurossi/vt100"
+)
+
+// HistorySize defines the maximum number of lines kept in the
+// history. The oldest lines are dropped when the history is full.
+const HistorySize = 1000
+
+// AddHistory adds the line to the history. The empty lines, the
+// duplicates of the latest line, and the masked lines are not added.
+func (rl *Readline) AddHistory(line string) {
+	if len(line) == 0 || rl.Mask != MaskNone {
+		return
+	}
+	if len(rl.history) > 0 && rl.history[len(rl.history)-1] == line {
+		return
+	}
+	if len(rl.history) >= HistorySize {
+		rl.history = rl.history[1:]
+	}
+	rl.history = append(rl.history, line)
+}
+
+// isearch implements the reverse incremental history search.
+type isearch struct {
+	term   []byte
+	match  int
+	failed bool
+	orig   string
+}
+
+// startSearch starts the reverse incremental history search.
+func (rl *Readline) startSearch() {
+	rl.search = &isearch{
+		match: -1,
+		orig:  rl.line(),
+	}
+	rl.state = rlSearch
+	rl.drawSearch()
+}
+
+// find finds the latest history line, starting from the index start
+// and proceeding towards older lines, that contains the search
+// term. It returns -1 if no line matches.
+func (s *isearch) find(history []string, start int) int {
+	if len(s.term) == 0 {
+		return -1
+	}
+	for i := start; i >= 0; i-- {
+		if strings.Contains(history[i], string(s.term)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// current returns the line that the search currently shows.
+func (s *isearch) current(history []string) string {
+	if s.match < 0 {
+		return s.orig
+	}
+	return history[s.match]
+}
+
+// update searches the term from the index start. If no line matches,
+// the search is marked failed and the previous match is kept.
+func (rl *Readline) update(start int) {
+	s := rl.search
+	idx := s.find(rl.history, start)
+	s.failed = idx < 0 && len(s.term) > 0
+	if idx >= 0 {
+		s.match = idx
+	}
+	rl.drawSearch()
+}
+
+func (rl *Readline) drawSearch() {
+	s := rl.search
+	fmt.Fprint(rl.stdout, "\r")
+	vt100.EraseLineTail(rl.stdout)
+	if s.failed {
+		fmt.Fprint(rl.stdout, "(failed reverse-i-search)`")
+	} else {
+		fmt.Fprint(rl.stdout, "(reverse-i-search)`")
+	}
+	fmt.Fprintf(rl.stdout, "%s': ", s.term)
+	rl.output([]byte(s.current(rl.history)))
+}
+
+// endSearch ends the search and sets the line to the argument line.
+func (rl *Readline) endSearch(line, prompt string) {
+	rl.search = nil
+	rl.state = rlStart
+
+	rl.tail = copy(rl.buf, []byte(line))
+	rl.cursor = rl.tail
+
+	fmt.Fprint(rl.stdout, "\r")
+	vt100.EraseLineTail(rl.stdout)
+	fmt.Fprintf(rl.stdout, "%s", prompt)
+	rl.output(rl.buf[:rl.tail])
+}
+
+func rlSearch(rl *Readline, b byte, prompt string) bool {
+	s := rl.search
+
+	switch b {
+	case 0x12: // C-r
+		if s.match > 0 {
+			rl.update(s.match - 1)
+		} else if s.match < 0 {
+			rl.update(len(rl.history) - 1)
+		} else {
+			s.failed = true
+			rl.drawSearch()
+		}
+
+	case 0x07: // C-g
+		rl.endSearch(s.orig, prompt)
+
+	case 0x1b: // ESC
+		// Accept the match and process the rest of the escape
+		// sequence, such as an arrow key, on the accepted line.
+		rl.endSearch(s.current(rl.history), prompt)
+		rl.state = rlESC
+
+	case 0x08, 0x7f: // BS, Delete
+		if len(s.term) > 0 {
+			s.term = s.term[:len(s.term)-1]
+			s.match = -1
+			rl.update(len(rl.history) - 1)
+		}
+
+	case '\n', '\r':
+		rl.endSearch(s.current(rl.history), prompt)
+		return true
+
+	default:
+		if !unicode.IsPrint(rune(b)) {
+			break
+		}
+		s.term = append(s.term, b)
+		start := s.match
+		if start < 0 {
+			start = len(rl.history) - 1
+		}
+		rl.update(start)
+	}
+	return false
+}
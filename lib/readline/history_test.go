@@ -0,0 +1,132 @@
+//
+// history_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var searchTests = []struct {
+	input  string
+	line   string
+	done   bool
+	output string
+}{
+	{
+		input:  "orig\x12ls",
+		line:   "ls /tmp",
+		output: "(reverse-i-search)`ls': ls /tmp",
+	},
+	{
+		input:  "orig\x12ls\x12\r",
+		line:   "ls -l",
+		done:   true,
+		output: "(reverse-i-search)`ls': ls -l",
+	},
+	{
+		input:  "orig\x12cat\x07",
+		line:   "orig",
+		output: "$ orig",
+	},
+	{
+		input:  "orig\x12ls\x12\x12",
+		line:   "ls -l",
+		output: "(failed reverse-i-search)`ls': ls -l",
+	},
+	{
+		input:  "\x12zzz",
+		line:   "",
+		output: "(failed reverse-i-search)`zzz': ",
+	},
+	{
+		input:  "\x12ech\x1b",
+		line:   "echo hi",
+		output: "$ echo hi",
+	},
+	{
+		input:  "\x12ech\x1b[D\x1b[Dx",
+		line:   "echo xhi",
+		output: "$ echo hi",
+	},
+	{
+		input:  "\x12lsx\x08",
+		line:   "ls /tmp",
+		output: "(reverse-i-search)`ls': ls /tmp",
+	},
+	{
+		input:  "\x12lsx\x7f",
+		line:   "ls /tmp",
+		output: "(reverse-i-search)`ls': ls /tmp",
+	},
+}
+
+func TestReverseSearch(t *testing.T) {
+	for _, test := range searchTests {
+		var stdout, stderr bytes.Buffer
+		rl := NewReadline(nil, &stdout, &stderr)
+		for _, line := range []string{
+			"ls -l", "cat foo", "ls /tmp", "echo hi",
+		} {
+			rl.AddHistory(line)
+		}
+		var done bool
+		for i := 0; i < len(test.input); i++ {
+			done = rl.input(test.input[i], "$ ")
+		}
+		if done != test.done {
+			t.Errorf("%q: done %v, expected %v",
+				test.input, done, test.done)
+		}
+		if !done && rl.search != nil {
+			// Accept the search without executing the line.
+			rl.input(0x1b, "$ ")
+		}
+		if rl.line() != test.line {
+			t.Errorf("%q: line %q, expected %q",
+				test.input, rl.line(), test.line)
+		}
+		if !strings.Contains(stdout.String(), test.output) {
+			t.Errorf("%q: output %q does not contain %q",
+				test.input, stdout.String(), test.output)
+		}
+		if stderr.Len() > 0 {
+			t.Errorf("%q: stderr: %q", test.input, stderr.String())
+		}
+	}
+}
+
+func TestAddHistory(t *testing.T) {
+	rl := NewReadline(nil, nil, nil)
+	rl.AddHistory("")
+	rl.AddHistory("ls")
+	rl.AddHistory("ls")
+	if len(rl.history) != 1 {
+		t.Errorf("history: got %q, expected [ls]", rl.history)
+	}
+	for i := 0; i < HistorySize+10; i++ {
+		rl.AddHistory(fmt.Sprintf("cmd %d", i))
+	}
+	if len(rl.history) != HistorySize {
+		t.Errorf("history size: got %d, expected %d",
+			len(rl.history), HistorySize)
+	}
+	if rl.history[0] != "cmd 10" {
+		t.Errorf("oldest line: got %q, expected %q",
+			rl.history[0], "cmd 10")
+	}
+
+	rl.Mask = MaskAsterisk
+	rl.AddHistory("secret")
+	if rl.history[len(rl.history)-1] == "secret" {
+		t.Errorf("masked line added to history")
+	}
+}
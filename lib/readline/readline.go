@@ -13,11 +13,16 @@ import (
 )
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"unicode"
 )
 
+// ErrInterrupt is returned when the line is canceled with Ctrl-C.
+var ErrInterrupt = errors.New("interrupt")
+
 // TabCompletion provides tab completions for the line.
 type TabCompletion func(line string) (expanded string, completions []string)
 
@@ -30,17 +35,25 @@ const (
 	MaskAsterisk
 )
 
-// Readline implements interactive line reader.
+// Readline implements interactive line reader. The PasteSeparator
+// replaces the line breaks of bracketed pastes so that a multi-line
+// paste becomes one line.
 type Readline struct {
-	Tab    TabCompletion
-	Mask   Mask
-	stdin  io.Reader
-	stdout io.Writer
-	stderr io.Writer
-	buf    []byte
-	state  rlState
-	cursor int
-	tail   int
+	Tab            TabCompletion
+	Mask           Mask
+	PasteSeparator string
+	stdin          io.Reader
+	stdout         io.Writer
+	stderr         io.Writer
+	buf            []byte
+	state          rlState
+	cursor         int
+	tail           int
+	history        []string
+	search         *isearch
+	intr           bool
+	seq            []byte
+	pasteNL        bool
 }
 
 type rlState func(rl *Readline, b byte, prompt string) bool
@@ -48,11 +61,12 @@ type rlState func(rl *Readline, b byte, prompt string) bool
 // NewReadline creates a new readline instance.
 func NewReadline(stdin io.Reader, stdout, stderr io.Writer) *Readline {
 	return &Readline{
-		stdin:  stdin,
-		stdout: stdout,
-		stderr: stderr,
-		buf:    make([]byte, 1024),
-		state:  rlStart,
+		PasteSeparator: " ",
+		stdin:          stdin,
+		stdout:         stdout,
+		stderr:         stderr,
+		buf:            make([]byte, 1024),
+		state:          rlStart,
 	}
 }
 
@@ -65,6 +79,7 @@ func (rl *Readline) Read(prompt string) (string, error) {
 
 	rl.cursor = 0
 	rl.tail = 0
+	rl.intr = false
 	fmt.Fprintf(rl.stdout, "%s", prompt)
 
 	var buf [1]byte
@@ -74,8 +89,13 @@ func (rl *Readline) Read(prompt string) (string, error) {
 			return rl.line(), err
 		}
 		if rl.input(buf[0], prompt) {
+			if rl.intr {
+				return "", ErrInterrupt
+			}
 			// Line read.
-			return rl.line(), nil
+			line := rl.line()
+			rl.AddHistory(line)
+			return line, nil
 		}
 	}
 }
@@ -107,14 +127,17 @@ func rlStart(rl *Readline, b byte, prompt string) bool {
 		return false
 
 	case 0x01: // C-a
-		for rl.cursor > 0 {
-			vt100.Backspace(rl.stdout)
-			rl.cursor--
-		}
+		rl.cursorStart()
 
 	case 0x02: // C-b
 		rl.cursorLeft()
 
+	case 0x03: // C-c
+		// Cancel the line.
+		fmt.Fprintf(rl.stdout, "^C")
+		rl.intr = true
+		return true
+
 	case 0x04: // C-d
 		if rl.cursor < rl.tail {
 			vt100.DeleteChar(rl.stdout)
@@ -123,9 +146,7 @@ func rlStart(rl *Readline, b byte, prompt string) bool {
 		}
 
 	case 0x05: // C-e
-		for rl.cursor < rl.tail {
-			rl.cursorRight()
-		}
+		rl.cursorEnd()
 
 	case 0x06: // C-f
 		rl.cursorRight()
@@ -166,7 +187,24 @@ func rlStart(rl *Readline, b byte, prompt string) bool {
 		fmt.Fprintf(rl.stdout, "%s", prompt)
 		rl.output(rl.buf[:rl.tail])
 
-	case 0x7f: // Delete
+	case 0x12: // C-r
+		if rl.Mask == MaskNone {
+			rl.startSearch()
+		}
+
+	case 0x15: // C-u
+		// Kill the line before the cursor and redraw the rest.
+		n := rl.cursor
+		rl.cursorStart()
+		copy(rl.buf, rl.buf[n:rl.tail])
+		rl.tail -= n
+		rl.output(rl.buf[:rl.tail])
+		vt100.EraseLineTail(rl.stdout)
+		for i := rl.tail; i > rl.cursor; i-- {
+			vt100.Backspace(rl.stdout)
+		}
+
+	case 0x08, 0x7f: // BS, Delete
 		if rl.cursor == 0 {
 			break
 		}
@@ -183,15 +221,7 @@ func rlStart(rl *Readline, b byte, prompt string) bool {
 			return true
 		}
 		if unicode.IsPrint(rune(b)) {
-			rl.insert(b)
-
-			// Print line.
-			rl.output(rl.buf[rl.cursor-1 : rl.tail])
-
-			// Move cursor back to its position.
-			for i := rl.tail; i > rl.cursor; i-- {
-				vt100.Backspace(rl.stdout)
-			}
+			rl.insertChar(b)
 		} else {
 			fmt.Fprintf(rl.stderr, "readline: skipping non-printable 0x%x\n", b)
 		}
@@ -202,6 +232,7 @@ func rlStart(rl *Readline, b byte, prompt string) bool {
 func rlESC(rl *Readline, b byte, prompt string) bool {
 	switch b {
 	case '[':
+		rl.seq = rl.seq[:0]
 		rl.state = rlCSI
 
 	default:
@@ -212,11 +243,29 @@ func rlESC(rl *Readline, b byte, prompt string) bool {
 }
 
 func rlCSI(rl *Readline, b byte, prompt string) bool {
+	if b >= '0' && b <= '9' || b == ';' {
+		// Parameter bytes.
+		rl.seq = append(rl.seq, b)
+		return false
+	}
 	switch b {
+	case '~':
+		if string(rl.seq) == "200" {
+			// Bracketed paste start.
+			rl.seq = rl.seq[:0]
+			rl.pasteNL = false
+			rl.state = rlPaste
+			return false
+		}
+		fmt.Fprintf(rl.stderr, "readline: CSI: unsupported: %s~", rl.seq)
 	case 'C':
 		rl.cursorRight()
 	case 'D':
 		rl.cursorLeft()
+	case 'H': // Home
+		rl.cursorStart()
+	case 'F': // End
+		rl.cursorEnd()
 	default:
 		fmt.Fprintf(rl.stderr, "readline: CSI: unsupported: b=0x%x", b)
 	}
@@ -224,6 +273,71 @@ func rlCSI(rl *Readline, b byte, prompt string) bool {
 	return false
 }
 
+// pasteEnd ends the bracketed paste.
+const pasteEnd = "\x1b[201~"
+
+// rlPaste inserts the bracketed paste as literal input until the
+// paste end sequence. The line breaks do not end the line.
+func rlPaste(rl *Readline, b byte, prompt string) bool {
+	rl.seq = append(rl.seq, b)
+	if strings.HasPrefix(pasteEnd, string(rl.seq)) {
+		if len(rl.seq) == len(pasteEnd) {
+			rl.seq = rl.seq[:0]
+			rl.state = rlStart
+		}
+		return false
+	}
+	// Not the end sequence: paste the pending bytes.
+	pending := append([]byte(nil), rl.seq[:len(rl.seq)-1]...)
+	rl.seq = rl.seq[:0]
+	for _, c := range pending {
+		rl.paste(c)
+	}
+	if b == pasteEnd[0] {
+		rl.seq = append(rl.seq, b)
+	} else {
+		rl.paste(b)
+	}
+	return false
+}
+
+// paste inserts the pasted character. The line breaks are replaced
+// with the PasteSeparator. The line breaks at the start and at the
+// end of the line, and the successive line breaks, produce no
+// separators.
+func (rl *Readline) paste(b byte) {
+	switch {
+	case b == '\r' || b == '\n':
+		rl.pasteNL = rl.tail > 0
+	case b == '\t':
+		rl.paste(' ')
+	case unicode.IsPrint(rune(b)):
+		if rl.pasteNL {
+			rl.pasteNL = false
+			for i := 0; i < len(rl.PasteSeparator); i++ {
+				rl.insertChar(rl.PasteSeparator[i])
+			}
+		}
+		rl.insertChar(b)
+	}
+}
+
+// insertChar inserts the character at the cursor and updates the
+// line on the screen.
+func (rl *Readline) insertChar(b byte) {
+	if !rl.insert(b) {
+		return
+	}
+
+	// Print line.
+	rl.output(rl.buf[rl.cursor-1 : rl.tail])
+
+	// Move cursor back to its position.
+	for i := rl.tail; i > rl.cursor; i-- {
+		vt100.Backspace(rl.stdout)
+	}
+}
+
 func (rl *Readline) cursorLeft() {
 	if rl.cursor > 0 {
 		vt100.Backspace(rl.stdout)
@@ -238,16 +352,23 @@ func (rl *Readline) cursorRight() {
 	}
 }
 
+func (rl *Readline) cursorStart() {
+	for rl.cursor > 0 {
+		rl.cursorLeft()
+	}
+}
+
+func (rl *Readline) cursorEnd() {
+	for rl.cursor < rl.tail {
+		rl.cursorRight()
+	}
+}
+
 func (rl *Readline) insert(b byte) bool {
 	if rl.tail >= len(rl.buf) {
 		return false
 	}
-
-	if rl.cursor < rl.tail {
-		for i := rl.tail + 1; i > rl.cursor; i-- {
-			rl.buf[i] = rl.buf[i-1]
-		}
-	}
+	copy(rl.buf[rl.cursor+1:], rl.buf[rl.cursor:rl.tail])
 	rl.buf[rl.cursor] = b
 
 	rl.cursor++
@@ -256,12 +377,9 @@ func (rl *Readline) insert(b byte) bool {
 	return true
 }
 
+// delete deletes the character before the cursor.
 func (rl *Readline) delete() {
-	if rl.cursor == rl.tail {
-		rl.cursor--
-	} else {
-		rl.cursor--
-		rl.buf = append(rl.buf[0:rl.cursor], rl.buf[rl.cursor+1:]...)
-	}
+	rl.cursor--
+	copy(rl.buf[rl.cursor:], rl.buf[rl.cursor+1:rl.tail])
 	rl.tail--
 }
@@ -0,0 +1,174 @@
+//
+// readline_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/vt100"
+)
+
+var editTests = []struct {
+	input  string
+	line   string
+	cursor int
+}{
+	{"abc", "abc", 3},
+	{"abc\x7f", "ab", 2},
+	{"abc\x08\x08x", "ax", 2},
+	{"abc\x1b[D\x1b[Dx", "axbc", 2},
+	{"abc\x1b[D\x1b[D\x1b[C\x7f", "ac", 1},
+	{"abc\x1b[D\x1b[D\x1b[D\x7f", "abc", 0},
+	{"abc\x1b[C", "abc", 3},
+	{"abc\x01x", "xabc", 1},
+	{"abc\x01\x05x", "abcx", 4},
+	{"abc\x02\x02\x06", "abc", 2},
+	{"abc\x1b[H\x04", "bc", 0},
+	{"abc\x1b[H\x1b[F\x04", "abc", 3},
+	{"abc\x15", "", 0},
+	{"hello world\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x15", "world", 0},
+	{"hello world\x01\x15", "hello world", 0},
+	{"hello world\x02\x02\x0b", "hello wor", 9},
+}
+
+func TestEdit(t *testing.T) {
+	const prompt = "$ "
+
+	for _, test := range editTests {
+		var stdout, stderr bytes.Buffer
+		rl := NewReadline(nil, &stdout, &stderr)
+		stdout.WriteString(prompt)
+		for i := 0; i < len(test.input); i++ {
+			if rl.input(test.input[i], prompt) {
+				t.Errorf("%q: unexpected end of line",
+					test.input)
+			}
+		}
+		if rl.line() != test.line || rl.cursor != test.cursor {
+			t.Errorf("%q: got %q at %d, expected %q at %d",
+				test.input, rl.line(), rl.cursor,
+				test.line, test.cursor)
+		}
+		if stderr.Len() > 0 {
+			t.Errorf("%q: stderr: %q", test.input, stderr.String())
+		}
+
+		// The terminal shows the edited line with the cursor at
+		// the editing position.
+		display := vt100.NewDisplay(80, 24)
+		emulator := vt100.NewEmulator(ioutil.Discard, ioutil.Discard,
+			display)
+		for _, r := range stdout.String() {
+			emulator.Input(int(r))
+		}
+		var sb strings.Builder
+		for _, ch := range display.Lines[0] {
+			if ch.Code != 0 {
+				sb.WriteRune(ch.Code)
+			}
+		}
+		screen := strings.TrimRight(sb.String(), " \u00a0")
+		expected := strings.TrimRight(prompt+test.line, " ")
+		if screen != expected {
+			t.Errorf("%q: screen %q, expected %q",
+				test.input, screen, expected)
+		}
+		if emulator.Cursor.X != len(prompt)+test.cursor {
+			t.Errorf("%q: screen cursor %d, expected %d",
+				test.input, emulator.Cursor.X,
+				len(prompt)+test.cursor)
+		}
+	}
+}
+
+func TestEditEnter(t *testing.T) {
+	for _, input := range []string{"ab\x7fc\r", "ab\x7fc\n"} {
+		rl := NewReadline(nil, ioutil.Discard, ioutil.Discard)
+		var done bool
+		for i := 0; i < len(input); i++ {
+			done = rl.input(input[i], "$ ")
+		}
+		if !done || rl.line() != "ac" {
+			t.Errorf("%q: got %q (%v), expected \"ac\"",
+				input, rl.line(), done)
+		}
+	}
+}
+
+func TestEditFull(t *testing.T) {
+	rl := NewReadline(nil, ioutil.Discard, ioutil.Discard)
+	for i := 0; i < len(rl.buf)+10; i++ {
+		rl.input('x', "$ ")
+	}
+	rl.input(0x01, "$ ")
+	rl.input('y', "$ ")
+	if rl.tail != len(rl.buf) || rl.buf[0] != 'x' {
+		t.Errorf("full buffer: tail %d, first %q", rl.tail, rl.buf[0])
+	}
+}
+
+func TestEditInterrupt(t *testing.T) {
+	var stdout bytes.Buffer
+	rl := NewReadline(nil, &stdout, ioutil.Discard)
+	input := "abc\x03"
+	var done bool
+	for i := 0; i < len(input); i++ {
+		done = rl.input(input[i], "$ ")
+	}
+	if !done || !rl.intr {
+		t.Errorf("%q: line not interrupted", input)
+	}
+	if !strings.HasSuffix(stdout.String(), "^C") {
+		t.Errorf("%q: output %q", input, stdout.String())
+	}
+}
+
+func TestBracketedPaste(t *testing.T) {
+	tests := []struct {
+		separator string
+		input     string
+		line      string
+	}{
+		{
+			separator: "; ",
+			input:     "x\x1b[200~\ncd /tmp\r\n\nls\t-l\n\x1b[201~",
+			line:      "x; cd /tmp; ls -l",
+		},
+		{
+			separator: " ",
+			input:     "\x1b[200~a\nb\x1b[2\x1b[201~c",
+			line:      "a b[2c",
+		},
+	}
+	for _, test := range tests {
+		var stderr bytes.Buffer
+		rl := NewReadline(nil, ioutil.Discard, &stderr)
+		rl.PasteSeparator = test.separator
+		for i := 0; i < len(test.input); i++ {
+			if rl.input(test.input[i], "$ ") {
+				t.Errorf("%q: unexpected end of line", test.input)
+			}
+		}
+		if rl.line() != test.line || rl.cursor != rl.tail {
+			t.Errorf("%q: got %q at %d, expected %q at %d",
+				test.input, rl.line(), rl.cursor, test.line, len(test.line))
+		}
+		if stderr.Len() > 0 {
+			t.Errorf("%q: stderr: %q", test.input, stderr.String())
+		}
+		// The pasted line is editable and Enter ends it.
+		rl.input(0x7f, "$ ")
+		if !rl.input('\r', "$ ") || rl.line() != test.line[:len(test.line)-1] {
+			t.Errorf("%q: edit after paste: got %q", test.input, rl.line())
+		}
+	}
+}
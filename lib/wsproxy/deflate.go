@@ -0,0 +1,56 @@
+//
+// deflate.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package wsproxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// MaxInflateSize limits the size of a decompressed frame payload.
+const MaxInflateSize = 16 * 1024 * 1024
+
+// DataFrame creates a data frame for the payload. If compress is
+// true, the payload is compressed into a FrameDeflate frame unless
+// the compression does not make it smaller. Each payload is
+// compressed independently so the frames never wait for more data in
+// the compressor.
+func DataFrame(payload []byte, compress bool) []byte {
+	if compress {
+		var buf bytes.Buffer
+		buf.WriteByte(byte(FrameDeflate))
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err == nil {
+			w.Write(payload)
+			w.Close()
+			if buf.Len() < 1+len(payload) {
+				return buf.Bytes()
+			}
+		}
+	}
+	return Frame(FrameData, payload)
+}
+
+// Inflate decompresses the payload of a FrameDeflate frame.
+func Inflate(payload []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(payload))
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxInflateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxInflateSize {
+		return nil, fmt.Errorf("decompressed frame too large")
+	}
+	return data, nil
+}
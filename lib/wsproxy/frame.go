@@ -0,0 +1,66 @@
+//
+// frame.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package wsproxy
+
+import (
+	"fmt"
+)
+
+// FrameType defines the type of the WebSocket messages that the
+// proxy and its clients exchange after the dial handshake. Each
+// message starts with the frame type byte, followed by the frame
+// payload.
+type FrameType byte
+
+// Frame types.
+const (
+	FrameData FrameType = iota
+	FrameKeepalive
+	FrameFlow
+	FrameClose
+	FrameUrgent
+	FrameDeflate
+)
+
+var frameTypes = map[FrameType]string{
+	FrameData:      "data",
+	FrameKeepalive: "keepalive",
+	FrameFlow:      "flow",
+	FrameClose:     "close",
+	FrameUrgent:    "urgent",
+	FrameDeflate:   "deflate",
+}
+
+func (t FrameType) String() string {
+	name, ok := frameTypes[t]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{FrameType %d}", t)
+}
+
+// Frame creates a message of the frame type with the payload.
+func Frame(t FrameType, payload []byte) []byte {
+	msg := make([]byte, 1+len(payload))
+	msg[0] = byte(t)
+	copy(msg[1:], payload)
+	return msg
+}
+
+// ParseFrame parses the frame type and payload from the message.
+func ParseFrame(msg []byte) (FrameType, []byte, error) {
+	if len(msg) == 0 {
+		return 0, nil, fmt.Errorf("empty frame")
+	}
+	t := FrameType(msg[0])
+	if _, ok := frameTypes[t]; !ok {
+		return 0, nil, fmt.Errorf("invalid frame type: %s", t)
+	}
+	return t, msg[1:], nil
+}
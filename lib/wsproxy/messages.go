@@ -9,15 +9,70 @@
 package wsproxy
 
 import (
+	"fmt"
 	"time"
 )
 
 type Dial struct {
 	Addr    string
 	Timeout time.Duration
+	// Network specifies the network, tcp or udp, of the
+	// connection. The empty network is tcp.
+	Network string
+	// Compress requests the compression of the data frames.
+	Compress bool
 }
 
 type Status struct {
 	Success bool
 	Error   string
+	Code    ErrorCode
+	// Urgent tells if the proxy can forward urgent data.
+	Urgent bool
+	// Compress tells if the data frames can be compressed.
+	Compress bool
+}
+
+// ErrorCode specifies the category of a failed dial.
+type ErrorCode int
+
+// Dial error codes.
+const (
+	ErrorNone ErrorCode = iota
+	ErrorUnknown
+	ErrorInvalid
+	ErrorRefused
+	ErrorNetworkUnreachable
+	ErrorHostUnreachable
+	ErrorNoHost
+	ErrorTimeout
+)
+
+var errorCodes = map[ErrorCode]string{
+	ErrorNone:               "No error",
+	ErrorUnknown:            "Unknown error",
+	ErrorInvalid:            "Invalid request",
+	ErrorRefused:            "Connection refused",
+	ErrorNetworkUnreachable: "Network unreachable",
+	ErrorHostUnreachable:    "Host unreachable",
+	ErrorNoHost:             "No such host",
+	ErrorTimeout:            "Connection timed out",
+}
+
+func (code ErrorCode) String() string {
+	name, ok := errorCodes[code]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{ErrorCode %d}", code)
+}
+
+type Resolve struct {
+	Host string
+}
+
+type Addresses struct {
+	Success bool
+	Error   string
+	Addrs   []string
 }
@@ -10,7 +10,7 @@ var ST_WEBSOCKET	= 0;
 var ST_CONNECTED	= 1;
 var ST_CLOSED		= 2;
 
-function WS(url, onOpen, onMessage, onError, onClose) {
+function WS(url, protocols, headers, onOpen, onMessage, onError, onClose) {
     var self = this;
 
     self.url = url;
@@ -21,7 +21,9 @@ function WS(url, onOpen, onMessage, onError, onClose) {
 
     self.state = ST_WEBSOCKET;
 
-    self.ws = new WebSocket(url);
+    // Browsers ignore the options argument. It is honored by
+    // WebSocket implementations that support handshake headers.
+    self.ws = new WebSocket(url, protocols, {headers: headers});
     self.ws.binaryType = 'arraybuffer';
 
     self.ws.onopen = function(evt) {
@@ -52,6 +54,8 @@ WS.prototype.onMessage = function(evt) {
             result.push(dv.getUint8(i));
         }
         this.goOnMessage(result);
+    } else if (typeof evt.data === "string") {
+        this.goOnMessage(evt.data);
     } else {
         console.log("ws.onmessage:", evt);
     }
@@ -85,14 +89,20 @@ WS.prototype.close = function() {
     this.ws.close();
 }
 
-function webSocketNew(url, onOpen, onMessage, onError, onClose) {
-    return new WS(url, onOpen, onMessage, onError, onClose);
+function webSocketNew(url, protocols, headers, onOpen, onMessage, onError,
+                      onClose) {
+    return new WS(url, protocols, headers, onOpen, onMessage, onError,
+                  onClose);
 }
 
 function webSocketSend(ws, data) {
     ws.send(data);
 }
 
+function webSocketBufferedAmount(ws) {
+    return ws.ws.bufferedAmount;
+}
+
 function webSocketClose(ws) {
     ws.close();
 }